@@ -1,9 +1,19 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 
 	"aistudio-exporter/internal/exporter"
 
@@ -11,47 +21,844 @@ import (
 )
 
 var (
-	format string
+	format     string
+	metadata   bool
+	roles      bool
+	userLabel  string
+	modelLabel string
+
+	thoughts string
+	perTurn  bool
+
+	noClobber bool
+	force     bool
+	backup    bool
+
+	filterRoles   []string
+	turns         string
+	minTokens     int
+	maxTokens     int
+	finishReasons []string
+	match         string
+
+	assetsDir string
+	blobs     bool
+	// assetStore is the asset directory opened for the current export.
+	assetStore *exporter.AssetStore
+
+	importFormat string
+	importModel  string
+
+	searchRoles    []string
+	searchLimit    int
+	searchJSON     bool
+	highlightStart string
+	highlightEnd   string
+
+	statsJSON bool
+
+	showCost   bool
+	pricesPath string
+	costJSON   bool
 )
 
-var rootCmd = &cobra.Command{
-	Use:   "aistudio-exporter",
-	Short: "Extracts text chunks from a JSON file into a single text document or database",
+var rootCmd = newRootCmd()
+
+// newRootCmd returns the root command with all subcommands.
+func newRootCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "aistudio-exporter",
+		Short: "Extracts text chunks from a JSON file into a single text document or database",
+	}
+	cmd.AddCommand(exportCmd, extractCodeCmd, importCmd, searchCmd, statsCmd, costCmd)
+	return cmd
 }
 
 var exportCmd = &cobra.Command{
-	Use:   "export [input.json] [output]",
-	Short: "Exports text chunks from JSON to a text file or SQLite database",
+	Use:   "export [input...] [output]",
+	Short: "Exports text chunks from JSON files to text, Markdown, HTML, JSONL or a SQLite database",
+	Long: `Exports text chunks from JSON files to text, Markdown, HTML, JSONL or a SQLite database.
+
+Inputs may be files, directories (searched recursively) or glob patterns. When
+more than a single file is selected, output is a directory that receives one
+file per input, or a single database shared by all inputs for the sqlite
+format.
+
+ChatGPT and Claude conversations.json exports are detected and exported one
+conversation at a time, like a batch.
+
+Use "-" as the input to read a single prompt from standard input, and as the
+output to write to standard output.
+
+With --assets, inline images and files are saved to the given directory and
+referenced from the export; the directory's manifest.json lists them together
+with the Drive files they refer to, which are not downloaded.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inputs := args[:len(args)-1]
+		output := args[len(args)-1]
+
+		if len(inputs) > 1 && slices.Contains(inputs, stdio) {
+			return fmt.Errorf("standard input cannot be combined with other inputs")
+		}
+		batch := inputs[0] != stdio && exporter.IsBatch(inputs)
+		if batch && output == stdio {
+			return fmt.Errorf("cannot write multiple exports to standard output")
+		}
+
+		store, err := openAssets()
+		if err != nil {
+			return err
+		}
+		assetStore = store
+		defer func() { assetStore = nil }()
+
+		if batch {
+			err := exportBatch(cmd, inputs, output)
+			if manifestErr := writeManifest(); err == nil {
+				err = manifestErr
+			}
+			return err
+		}
+
+		stdin := cmd.InOrStdin()
+		if inputs[0] == stdio && outputExtension() != "" {
+			// Each conversation of an export would replace the one before.
+			inputFormat, r, err := exporter.DetectFormat(stdin)
+			if err != nil {
+				return err
+			}
+			if inputFormat != exporter.AIStudioInput {
+				return fmt.Errorf("standard input holds a %s export; export it from a file to a directory, or to a database with -f sqlite", inputFormat)
+			}
+			stdin = r
+		}
+
+		writer, err := newWriter(output, cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+
+		if inputs[0] == stdio {
+			err = exporter.ExportReaderContext(cmd.Context(), stdin, stdio, writer)
+		} else {
+			err = exporter.ExportChunksContext(cmd.Context(), inputs[0], writer)
+		}
+		if closeErr := closeWriter(writer); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeManifest(); err != nil {
+			return err
+		}
+
+		// Keep standard output clean for the export itself.
+		status := cmd.OutOrStdout()
+		if output == stdio {
+			status = cmd.ErrOrStderr()
+		}
+		fmt.Fprintf(status, "Successfully exported from %s to %s (format: %s)\n", inputs[0], output, format)
+		printImportStats(status, writer)
+		return nil
+	},
+}
+
+// newWriter returns the writer for the selected format writing to output, or
+// to stdout if output is "-", behind the chunk filters selected by flags.
+func newWriter(output string, stdout io.Writer) (exporter.Writer, error) {
+	writer, err := newFormatWriter(output, stdout)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := chunkFilter()
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return writer, nil
+	}
+	return &exporter.FilterWriter{Writer: writer, Filter: filter}, nil
+}
+
+// closeWriter closes writer if it holds resources across exports, such as
+// the database of the sqlite format.
+func closeWriter(writer exporter.Writer) error {
+	if c, ok := writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// chunkFilter returns the filter built from the filter flags, or nil if
+// none is set.
+func chunkFilter() (exporter.Filter, error) {
+	var filters []exporter.Filter
+	if len(filterRoles) > 0 {
+		filters = append(filters, exporter.RoleFilter(filterRoles...))
+	}
+	if turns != "" {
+		from, to, err := exporter.ParseTurnRange(turns)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, exporter.TurnFilter(from, to))
+	}
+	if minTokens > 0 || maxTokens > 0 {
+		filters = append(filters, exporter.TokenFilter(minTokens, maxTokens))
+	}
+	if len(finishReasons) > 0 {
+		filters = append(filters, exporter.FinishReasonFilter(finishReasons...))
+	}
+	if match != "" {
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --match pattern: %w", err)
+		}
+		filters = append(filters, exporter.TextFilter(re))
+	}
+
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	return exporter.AllFilters(filters...), nil
+}
+
+// openAssets opens the asset directory selected by --assets, or returns nil
+// if it is not set.
+func openAssets() (*exporter.AssetStore, error) {
+	if assetsDir == "" {
+		return nil, nil
+	}
+	if f, ok := exporter.Lookup(format); ok && !f.Assets {
+		return nil, fmt.Errorf("the %s format does not support --assets", f.Name)
+	}
+	return exporter.NewAssetStore(assetsDir)
+}
+
+// writeManifest writes the manifest of the asset directory, if one is open.
+func writeManifest() error {
+	if assetStore == nil {
+		return nil
+	}
+	return assetStore.WriteManifest()
+}
+
+// stdio is the input or output name that selects standard input or output.
+const stdio = "-"
+
+// newFormatWriter returns the writer for the selected format writing to
+// output, or to stdout if output is "-".
+func newFormatWriter(output string, stdout io.Writer) (exporter.Writer, error) {
+	f, ok := exporter.Lookup(format)
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %s (supported: %s)", format, formatList())
+	}
+	if blobs && f.Name != "sqlite" {
+		return nil, fmt.Errorf("--blobs requires the sqlite format")
+	}
+	if showCost && f.Name != "sqlite" {
+		return nil, fmt.Errorf("--cost requires the sqlite format")
+	}
+	if (noClobber || backup) && f.Name == "sqlite" {
+		// Conversations are added to an existing database, never replaced.
+		return nil, fmt.Errorf("--no-clobber and --backup do not apply to the sqlite format, which adds to an existing database")
+	}
+
+	opts := exporter.WriterOptions{
+		OutputPath: output,
+		Clobber:    clobberMode(),
+		Metadata:   metadata,
+		Roles:      roles,
+		UserLabel:  userLabel,
+		ModelLabel: modelLabel,
+		PerTurn:    perTurn,
+		Assets:     assetStore,
+		Blobs:      blobs,
+	}
+	if output == stdio {
+		opts.Output, opts.OutputPath = stdout, ""
+	}
+
+	thoughtMode, err := exporter.ParseThoughtMode(thoughts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Thoughts = thoughtMode
+
+	if showCost {
+		prices, err := loadPrices()
+		if err != nil {
+			return nil, err
+		}
+		opts.Prices = &prices
+	}
+	return f.New(opts)
+}
+
+// formatList lists the registered export formats with their aliases, e.g.
+// "html, md (markdown) or txt (text)".
+func formatList() string {
+	var names []string
+	for _, f := range exporter.List() {
+		name := f.Name
+		if len(f.Aliases) > 0 {
+			name += " (" + strings.Join(f.Aliases, ", ") + ")"
+		}
+		names = append(names, name)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// clobberMode returns what to do with existing output files according to
+// the --no-clobber, --force and --backup flags, which are mutually
+// exclusive.
+func clobberMode() exporter.ClobberMode {
+	switch {
+	case force:
+		return exporter.Overwrite
+	case noClobber:
+		return exporter.NoClobber
+	case backup:
+		return exporter.Backup
+	default:
+		return exporter.Overwrite
+	}
+}
+
+// outputExtension returns the file extension of per-file batch outputs for
+// the selected format, or "" when all inputs share one output.
+func outputExtension() string {
+	f, _ := exporter.Lookup(format)
+	return f.Extension
+}
+
+// exportBatch exports every input selected by args and prints a summary to
+// the output of cmd.
+func exportBatch(cmd *cobra.Command, args []string, output string) error {
+	ctx := cmd.Context()
+	writer, err := newWriter(output, nil)
+	if err != nil {
+		return err
+	}
+
+	inputs, err := exporter.ExpandInputs(args)
+	if err != nil {
+		return err
+	}
+
+	// All inputs share the database writer, which counts what they add.
+	newBatchWriter := func(exporter.Input) (exporter.Writer, error) {
+		return writer, nil
+	}
+	if ext := outputExtension(); ext != "" {
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return fmt.Errorf("error creating output directory: %w", err)
+		}
+		written := make(map[string]string)
+		newBatchWriter = func(input exporter.Input) (exporter.Writer, error) {
+			path := input.OutputPath(output, ext)
+			if previous, ok := written[path]; ok {
+				return nil, fmt.Errorf("output %s is already written by %s", path, previous)
+			}
+			written[path] = input.Path
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return nil, fmt.Errorf("error creating output directory: %w", err)
+			}
+			return newWriter(path, nil)
+		}
+	}
+
+	result := exporter.ExportBatchContext(ctx, inputs, newBatchWriter)
+	if err := closeWriter(writer); err != nil {
+		return err
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Failed to export %s: %v\n", failure.Path, failure.Err)
+	}
+	// ChatGPT and Claude exports count once per conversation.
+	total := max(len(inputs), len(result.Succeeded)+len(result.Failed))
+	fmt.Fprintf(cmd.OutOrStdout(), "Exported %d of %d files to %s (format: %s)\n", len(result.Succeeded), total, output, format)
+	printImportStats(cmd.OutOrStdout(), writer)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%d of %d files failed to export", len(result.Failed), total)
+	}
+	return nil
+}
+
+// printImportStats reports what a SQLite export added to the database.
+func printImportStats(out io.Writer, writer exporter.Writer) {
+	if fw, ok := writer.(*exporter.FilterWriter); ok {
+		writer = fw.Writer
+	}
+	sw, ok := writer.(*exporter.SQLiteWriter)
+	if !ok {
+		return
+	}
+	stats := sw.Stats
+	fmt.Fprintf(out, "Conversations: %d inserted, %d updated, %d skipped (%d new messages)\n",
+		stats.Inserted, stats.Updated, stats.Skipped, stats.Messages)
+}
+
+var extractCodeCmd = &cobra.Command{
+	Use:   "extract-code [input] [output-dir]",
+	Short: "Extracts fenced code blocks from model turns into source files",
+	Long: `Extracts fenced code blocks from model turns into source files.
+
+Each block is written to a directory named after its turn, e.g.
+turn-004/main.go. The file name comes from the fence's info string (e.g.
+"go main.go"), a comment on the first line (e.g. "// main.go") or a line
+naming the file right before the fence; otherwise blocks are numbered and the
+extension is inferred from the language tag. index.json maps every file back
+to its turn. The conversations of a ChatGPT or Claude export are each written
+to a subdirectory named after their title and ID. Use "-" as the input to
+read from standard input.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		input, output := args[0], args[1]
+		writer := &exporter.CodeWriter{OutputDir: output}
+
+		var r io.Reader = cmd.InOrStdin()
+		if input != stdio {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("error reading input file: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := exporter.ExtractCodeContext(cmd.Context(), r, input, writer); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Extracted %d code blocks from %s to %s\n", len(writer.Files), input, output)
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [transcript] [output.json]",
+	Short: "Builds an AI Studio prompt JSON from a text or Markdown transcript",
+	Long: `Builds an AI Studio prompt JSON from a text or Markdown transcript.
+
+The transcript has the shape of a text export with --roles (and optionally
+--metadata) or of a Markdown export. Run settings and the system instruction
+are taken from its metadata; without metadata, default run settings are used.
+Use "-" as the transcript or output for standard input or output.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		input, output := args[0], args[1]
+
+		transcriptFormat, err := transcriptFormat(input)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader = cmd.InOrStdin()
+		if input != stdio {
+			f, err := os.Open(input)
+			if err != nil {
+				return fmt.Errorf("error reading input file: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		root, err := exporter.ImportTranscript(r, exporter.TranscriptOptions{
+			Format:     transcriptFormat,
+			UserLabel:  userLabel,
+			ModelLabel: modelLabel,
+		})
+		if err != nil {
+			return err
+		}
+		if importModel != "" {
+			root.RunSettings.Model = importModel
+		}
+
+		writer := &exporter.PromptWriter{OutputPath: output}
+		status := cmd.OutOrStdout()
+		if output == stdio {
+			writer = &exporter.PromptWriter{Output: cmd.OutOrStdout()}
+			status = cmd.ErrOrStderr()
+		}
+		if err := writer.Write(root); err != nil {
+			return err
+		}
+		fmt.Fprintf(status, "Imported %d chunks from %s to %s\n", len(root.ChunkedPrompt.Chunks), input, output)
+		return nil
+	},
+}
+
+// transcriptFormat returns the transcript format selected by --format or,
+// by default, by the extension of input.
+func transcriptFormat(input string) (exporter.TranscriptFormat, error) {
+	name := importFormat
+	if name == "" {
+		name = "txt"
+		if ext := strings.ToLower(filepath.Ext(input)); ext == ".md" || ext == ".markdown" {
+			name = "md"
+		}
+	}
+	switch strings.ToLower(name) {
+	case "txt", "text":
+		return exporter.TextTranscript, nil
+	case "md", "markdown":
+		return exporter.MarkdownTranscript, nil
+	default:
+		return 0, fmt.Errorf("unsupported transcript format: %s (supported: txt, md)", name)
+	}
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search [database] [query]",
+	Short: "Searches messages in a SQLite export using full-text search",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		input := args[0]
-		output := args[1]
-
-		var writer exporter.Writer
-		switch strings.ToLower(format) {
-		case "txt", "text":
-			writer = &exporter.TextWriter{OutputPath: output}
-		case "sqlite", "db":
-			writer = &exporter.SQLiteWriter{DBPath: output}
+		results, err := exporter.Search(args[0], args[1], exporter.SearchOptions{
+			Roles:          searchRoles,
+			Limit:          searchLimit,
+			HighlightStart: highlightStart,
+			HighlightEnd:   highlightEnd,
+		})
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if searchJSON {
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			if results == nil {
+				results = []exporter.SearchResult{}
+			}
+			return encoder.Encode(results)
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tTURN\tROLE\tSNIPPET")
+		for _, result := range results {
+			snippet := strings.Join(strings.Fields(result.Snippet), " ")
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", result.SourcePath, result.Turn, result.Role, snippet)
+		}
+		return tw.Flush()
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [input...]",
+	Short: "Reports turn, token and word counts of prompts or a SQLite export",
+	Long: `Reports turn, token and word counts of prompts or a SQLite export.
+
+Inputs may be files, directories (searched recursively), glob patterns, zip
+archives, ChatGPT and Claude exports or databases written by the sqlite
+format, which are recognised by their content. Each conversation is listed
+with its model, turns per role, answer and thought chunks, summed tokenCount,
+words, characters, longest turn (by words) and finish reasons, followed by the
+totals. Thoughts are included. Use "-" as the input to read from standard
+input.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		writer := &exporter.StatsWriter{}
+		if showCost {
+			prices, err := loadPrices()
+			if err != nil {
+				return err
+			}
+			writer.Prices = &prices
+		}
+
+		failed, err := readInputs(cmd, args, writer)
+		if err != nil {
+			return err
+		}
+
+		summary := exporter.Summarize(writer.Stats)
+		if statsJSON {
+			err = printStatsJSON(cmd.OutOrStdout(), writer.Stats, summary)
+		} else {
+			err = printStats(cmd.OutOrStdout(), writer.Stats, summary)
+		}
+		if err != nil {
+			return err
+		}
+		return readFailures(failed)
+	},
+}
+
+// readInputs passes every conversation selected by args to writer, as the
+// stats and cost commands read them: "-" is standard input, SQLite exports
+// are read back from their tables and other arguments are expanded like
+// export inputs. Inputs that cannot be read are reported and counted.
+func readInputs(cmd *cobra.Command, args []string, writer exporter.ContextWriter) (failed int, err error) {
+	ctx := cmd.Context()
+	for _, arg := range args {
+		switch {
+		case arg == stdio:
+			err = exporter.ExportReaderContext(ctx, cmd.InOrStdin(), stdio, writer)
+		case exporter.IsDatabase(arg):
+			err = exporter.ReadDatabase(arg, func(root exporter.Root) error {
+				return writer.WriteContext(ctx, root)
+			})
 		default:
-			return fmt.Errorf("unsupported format: %s (supported: txt, sqlite)", format)
+			var inputs []exporter.Input
+			inputs, err = exporter.ExpandInputs([]string{arg})
+			if err == nil {
+				result := exporter.ExportBatchContext(ctx, inputs, func(exporter.Input) (exporter.Writer, error) {
+					return writer, nil
+				})
+				for _, failure := range result.Failed {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Failed to read %s: %v\n", failure.Path, failure.Err)
+				}
+				failed += len(result.Failed)
+			}
+		}
+		if err != nil {
+			return failed, err
+		}
+		if err := ctx.Err(); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+// readFailures returns an error if inputs could not be read.
+func readFailures(failed int) error {
+	if failed > 0 {
+		return fmt.Errorf("%d inputs could not be read", failed)
+	}
+	return nil
+}
+
+// loadPrices returns the price table selected by --prices, or the default
+// one.
+func loadPrices() (exporter.PriceTable, error) {
+	if pricesPath == "" {
+		return exporter.DefaultPriceTable(), nil
+	}
+	return exporter.LoadPriceTable(pricesPath)
+}
+
+// printStatsJSON prints the statistics of each conversation and their
+// totals as a JSON object.
+func printStatsJSON(out io.Writer, conversations []exporter.Stats, summary exporter.StatsSummary) error {
+	if conversations == nil {
+		conversations = []exporter.Stats{}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Conversations []exporter.Stats      `json:"conversations"`
+		Total         exporter.StatsSummary `json:"total"`
+	}{conversations, summary})
+}
+
+// printStats prints the statistics of each conversation and their totals as
+// a table.
+func printStats(out io.Writer, conversations []exporter.Stats, summary exporter.StatsSummary) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	header := "SOURCE\tMODEL\tTURNS\tANSWERS\tTHOUGHTS\tTOKENS\tTHOUGHT TOKENS\tWORDS\tCHARACTERS\tLONGEST TURN\tFINISH REASONS"
+	if showCost {
+		header += "\tCOST"
+	}
+	fmt.Fprintln(tw, header)
+	row := func(source, model string, stats exporter.Stats) {
+		longest := "-"
+		if turn := stats.LongestTurn; turn != nil {
+			longest = fmt.Sprintf("#%d %s, %d words", turn.Turn, turn.Role, turn.Words)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s",
+			source, valueOrDash(model), formatCounts(stats.Turns), stats.AnswerChunks, stats.ThoughtChunks,
+			stats.Tokens, stats.ThoughtTokens, stats.Words, stats.Characters, longest, formatCounts(stats.FinishReasons))
+		if showCost {
+			fmt.Fprintf(tw, "\t%s", formatCost(stats.Cost))
+		}
+		fmt.Fprintln(tw)
+	}
+	for _, stats := range conversations {
+		row(stats.Source, stats.Model, stats)
+	}
+	row(fmt.Sprintf("TOTAL (%d)", summary.Conversations), formatCounts(summary.Models), summary.Stats)
+	return tw.Flush()
+}
+
+// formatCounts formats counts as "key=n" pairs sorted by key, e.g.
+// "model=3 user=3".
+func formatCounts(counts map[string]int) string {
+	var pairs []string
+	for _, key := range slices.Sorted(maps.Keys(counts)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", key, counts[key]))
+	}
+	return valueOrDash(strings.Join(pairs, " "))
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+var costCmd = &cobra.Command{
+	Use:   "cost [input...]",
+	Short: "Estimates the cost of conversations from their token counts",
+	Long: `Estimates the cost of conversations from their token counts.
+
+Inputs are selected like those of stats. Each conversation is priced as if it
+was run turn by turn: every model turn is a request whose input is the system
+instruction and every chunk before it except thoughts, and whose output is the
+model's answer, with thoughts priced as thinking tokens. Tokens are taken from
+tokenCount and estimated at about four characters per token where it is
+missing.
+
+Prices are looked up by the model of runSettings in a YAML or JSON price table
+given with --prices, per million tokens:
+
+  currency: USD
+  models:
+    gemini-2.5-pro: {input: 1.25, output: 10, thinking: 10}
+
+Without --prices, a built-in table of list prices for common Gemini models is
+used.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prices, err := loadPrices()
+		if err != nil {
+			return err
+		}
+		writer := &exporter.CostWriter{Prices: prices}
+		failed, err := readInputs(cmd, args, writer)
+		if err != nil {
+			return err
+		}
+
+		var total exporter.Cost
+		unpriced := make(map[string]bool)
+		for _, cost := range writer.Costs {
+			total.Add(cost)
+			if !cost.Priced {
+				unpriced[cost.Model] = true
+			}
+		}
+		for _, model := range slices.Sorted(maps.Keys(unpriced)) {
+			if model == "" {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Conversations without a model are not priced")
+				continue
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "No price for model %s; add it to the price table\n", model)
+		}
+		if total.Mixed() {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Costs in different currencies are not totalled")
 		}
 
-		if err := exporter.ExportChunks(input, writer); err != nil {
+		if costJSON {
+			costs := writer.Costs
+			if costs == nil {
+				costs = []exporter.Cost{}
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(struct {
+				Conversations []exporter.Cost `json:"conversations"`
+				Total         exporter.Cost   `json:"total"`
+			}{costs, total})
+		} else {
+			err = printCosts(cmd.OutOrStdout(), writer.Costs, total)
+		}
+		if err != nil {
 			return err
 		}
-		fmt.Printf("Successfully exported from %s to %s (format: %s)\n", input, output, format)
-		return nil
+		return readFailures(failed)
 	},
 }
 
+// printCosts prints the cost of each conversation and their total as a
+// table.
+func printCosts(out io.Writer, costs []exporter.Cost, total exporter.Cost) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tMODEL\tINPUT TOKENS\tOUTPUT TOKENS\tTHINKING TOKENS\tESTIMATED\tCOST")
+	row := func(source, model string, cost exporter.Cost) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", source, valueOrDash(model),
+			cost.InputTokens, cost.OutputTokens, cost.ThinkingTokens, cost.EstimatedTokens, formatCost(&cost))
+	}
+	for _, cost := range costs {
+		row(cost.Source, cost.Model, cost)
+	}
+	row(fmt.Sprintf("TOTAL (%d)", len(costs)), "", total)
+	return tw.Flush()
+}
+
+// formatCost formats an estimated cost, e.g. "0.0123 USD", or "-" if it is
+// not known.
+func formatCost(cost *exporter.Cost) string {
+	if cost == nil || !cost.Priced {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f %s", cost.Total, cost.Currency)
+}
+
 func init() {
-	exportCmd.Flags().StringVarP(&format, "format", "f", "txt", "Output format: txt or sqlite")
+	exportCmd.Flags().StringVarP(&format, "format", "f", "txt", "Output format: "+formatList())
+	exportCmd.Flags().BoolVar(&metadata, "metadata", false, "Include run settings and system instruction in the output")
+	exportCmd.Flags().BoolVar(&roles, "roles", false, "Prefix each text chunk with a speaker label")
+	exportCmd.Flags().StringVar(&userLabel, "user-label", exporter.DefaultUserLabel, "Speaker label for user turns (with --roles)")
+	exportCmd.Flags().StringVar(&modelLabel, "model-label", exporter.DefaultModelLabel, "Speaker label for model turns (with --roles)")
+	exportCmd.Flags().BoolVar(&perTurn, "per-turn", false, "Emit one example per user/model turn pair instead of per conversation (jsonl formats)")
+	exportCmd.Flags().StringVar(&thoughts, "thoughts", "exclude", "Thoughts to export: exclude, include, only, or separate (into a sibling file or table)")
+	exportCmd.Flags().BoolVar(&noClobber, "no-clobber", false, "Fail instead of replacing existing output files")
+	exportCmd.Flags().BoolVar(&force, "force", false, "Replace existing output files (default)")
+	exportCmd.Flags().BoolVar(&backup, "backup", false, "Keep existing output files as <output>.bak before replacing them")
+	exportCmd.MarkFlagsMutuallyExclusive("no-clobber", "force", "backup")
+	exportCmd.Flags().StringSliceVar(&filterRoles, "role", nil, "Only export chunks with this role (repeatable)")
+	exportCmd.Flags().StringVar(&turns, "turns", "", "Only export these turns, e.g. 10-25, 10- or -25 (a turn is a run of chunks with the same role)")
+	exportCmd.Flags().IntVar(&minTokens, "min-tokens", 0, "Only export chunks with at least this many tokens")
+	exportCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Only export chunks with at most this many tokens")
+	exportCmd.Flags().StringSliceVar(&finishReasons, "finish-reason", nil, "Only export chunks with this finish reason, e.g. STOP (repeatable)")
+	exportCmd.Flags().StringVar(&match, "match", "", "Only export chunks whose text matches this regular expression")
+	exportCmd.Flags().StringVar(&assetsDir, "assets", "", "Save inline images and files to this directory and link them from the export")
+	exportCmd.Flags().BoolVar(&blobs, "blobs", false, "Store the content of inline attachments in the database (sqlite format)")
+	exportCmd.Flags().BoolVar(&showCost, "cost", false, "Store the estimated cost of each conversation in the database (sqlite format)")
+	exportCmd.Flags().StringVar(&pricesPath, "prices", "", "YAML or JSON price table for --cost (default: built-in Gemini prices)")
+
+	importCmd.Flags().StringVarP(&importFormat, "format", "f", "", "Transcript format: txt or md (default: from the file extension, else txt)")
+	importCmd.Flags().StringVar(&importModel, "model", "", "Model to set in the run settings, e.g. models/gemini-2.5-flash")
+	importCmd.Flags().StringVar(&userLabel, "user-label", exporter.DefaultUserLabel, "Speaker label of user turns in text transcripts")
+	importCmd.Flags().StringVar(&modelLabel, "model-label", exporter.DefaultModelLabel, "Speaker label of model turns in text transcripts")
+
+	searchCmd.Flags().StringSliceVar(&searchRoles, "role", nil, "Only match messages with this role (repeatable)")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 20, "Maximum number of results (0 for no limit)")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "Print results as JSON")
+	searchCmd.Flags().StringVar(&highlightStart, "highlight-start", exporter.DefaultHighlightStart, "Marker inserted before matched terms")
+	searchCmd.Flags().StringVar(&highlightEnd, "highlight-end", exporter.DefaultHighlightEnd, "Marker inserted after matched terms")
+
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Print statistics as JSON")
+	statsCmd.Flags().BoolVar(&showCost, "cost", false, "Include the estimated cost of each conversation")
+	statsCmd.Flags().StringVar(&pricesPath, "prices", "", "YAML or JSON price table for --cost (default: built-in Gemini prices)")
+
+	costCmd.Flags().StringVar(&pricesPath, "prices", "", "YAML or JSON price table (default: built-in Gemini prices)")
+	costCmd.Flags().BoolVar(&costJSON, "json", false, "Print costs as JSON")
 }
 
 func main() {
-	rootCmd.AddCommand(exportCmd)
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel on the first interrupt so exports can clean up; restoring the
+	// default handling afterwards lets a second one terminate immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
 }
@@ -2,9 +2,11 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"gorm.io/driver/sqlite"
@@ -12,41 +14,26 @@ import (
 
 	"aistudio-exporter/internal/exporter"
 
-	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func resetRootCmd() {
-	rootCmd = &cobra.Command{
-		Use:   "aistudio-exporter",
-		Short: "Extracts text chunks from a JSON file into a single text document or database",
-	}
-	
-	format = "txt" // reset format flag
-	
-	exportCmd = &cobra.Command{
-		Use:   "export [input.json] [output]",
-		Short: "Exports text chunks from JSON to a text file or SQLite database",
-		Args:  cobra.ExactArgs(2),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			input := args[0]
-			output := args[1]
-
-			var writer exporter.Writer
-			switch format {
-			case "txt", "text":
-				writer = &exporter.TextWriter{OutputPath: output}
-			case "sqlite", "db":
-				writer = &exporter.SQLiteWriter{DBPath: output}
-			default:
-				return fmt.Errorf("unsupported format: %s (supported: txt, sqlite)", format)
-			}
-
-			return exporter.ExportChunks(input, writer)
-		},
+	rootCmd = newRootCmd()
+	for _, cmd := range rootCmd.Commands() {
+		resetFlags(cmd.Flags())
 	}
+}
 
-	exportCmd.Flags().StringVarP(&format, "format", "f", "txt", "Output format: txt or sqlite")
-	rootCmd.AddCommand(exportCmd)
+// resetFlags restores the default values of flags set by an earlier test.
+func resetFlags(flags *pflag.FlagSet) {
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	})
 }
 
 func TestExportCmd_TextFormat(t *testing.T) {
@@ -183,6 +170,19 @@ func TestExportCmd_UnsupportedFormat(t *testing.T) {
 	err = rootCmd.Execute()
 	if err == nil {
 		t.Error("Expected error for unsupported format, got nil")
+	} else if !strings.Contains(err.Error(), "sqlite (db) or txt (text)") {
+		t.Errorf("Expected the error to list the formats, got %v", err)
+	}
+}
+
+func TestExportCmd_FormatHelp(t *testing.T) {
+	resetRootCmd()
+
+	usage := exportCmd.Flags().Lookup("format").Usage
+	for _, format := range exporter.List() {
+		if !strings.Contains(usage, format.Name) {
+			t.Errorf("Expected --format help %q to list %s", usage, format.Name)
+		}
 	}
 }
 
@@ -228,6 +228,10 @@ func TestExportCmd_FormatAliases(t *testing.T) {
 		format string
 	}{
 		{"text alias", "text"},
+		{"markdown alias", "markdown"},
+		{"html", "html"},
+		{"openai jsonl", "openai-jsonl"},
+		{"gemini jsonl", "gemini-jsonl"},
 		{"db alias", "db"},
 	}
 
@@ -248,7 +252,7 @@ func TestExportCmd_FormatAliases(t *testing.T) {
 			tmpInput.Close()
 
 			var outputPath string
-			if tt.format == "text" {
+			if tt.format != "db" {
 				tmpOutput, err := os.CreateTemp("", "output_*.txt")
 				if err != nil {
 					t.Fatal(err)
@@ -342,3 +346,289 @@ func TestExportCmd_DefaultFormat(t *testing.T) {
 		t.Errorf("Expected 'Test', got %q", string(result))
 	}
 }
+
+func TestExportCmd_Batch(t *testing.T) {
+	resetRootCmd()
+
+	dir := t.TempDir()
+	inputDir := filepath.Join(dir, "prompts")
+	if err := os.Mkdir(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"a.json": `{"chunkedPrompt": {"chunks": [{"text": "A", "role": "user"}]}}`,
+		"b.json": `{"chunkedPrompt": {"chunks": [{"text": "B", "role": "user"}]}}`,
+		"c.json": `{"chunkedPrompt": `,
+	} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outputDir := filepath.Join(dir, "out")
+
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(errOut)
+	rootCmd.SetArgs([]string{"export", inputDir, outputDir, "-f", "md"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("Expected error for the malformed input, got nil")
+	}
+
+	expected := fmt.Sprintf("Exported 2 of 3 files to %s (format: md)\n", outputDir)
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("Output = %q, want it to start with %q", out.String(), expected)
+	}
+	if !strings.Contains(errOut.String(), "Failed to export "+filepath.Join(inputDir, "c.json")) {
+		t.Errorf("Expected the failure on stderr, got %q", errOut.String())
+	}
+}
+
+func TestExportCmd_ClobberFlags(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.json")
+	if err := os.WriteFile(input, []byte(`{"chunkedPrompt": {"chunks": [{"text": "New"}]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "output.txt")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantErr  bool
+		expected string
+	}{
+		{"Force", []string{"export", input, output, "--force"}, false, "New"},
+		{"NoClobber", []string{"export", input, output, "--no-clobber"}, true, "Old"},
+		{"SQLite NoClobber", []string{"export", input, filepath.Join(dir, "out.db"), "-f", "sqlite", "--no-clobber"}, true, "Old"},
+		{"SQLite Backup", []string{"export", input, filepath.Join(dir, "out.db"), "-f", "db", "--backup"}, true, "Old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRootCmd()
+			if err := os.WriteFile(output, []byte("Old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			buf := new(bytes.Buffer)
+			rootCmd.SetOut(buf)
+			rootCmd.SetErr(buf)
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			content, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("Output = %q, want %q", content, tt.expected)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.db")); err == nil {
+		t.Error("Expected no database to be created")
+	}
+}
+
+func TestSearchCmd(t *testing.T) {
+	resetRootCmd()
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	writer := &exporter.SQLiteWriter{DBPath: dbPath}
+	root := exporter.Root{
+		Source: "input.json",
+		ChunkedPrompt: exporter.ChunkedPrompt{
+			Chunks: []exporter.Chunk{
+				{Text: "Tell me about gophers", Role: exporter.RoleUser},
+				{Text: "Gophers are burrowing rodents", Role: exporter.RoleModel},
+			},
+		},
+	}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"search", dbPath, "gophers", "--role", "model", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	var results []exporter.SearchResult
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to decode output %q: %v", buf.String(), err)
+	}
+	if len(results) != 1 || results[0].Role != exporter.RoleModel || results[0].SourcePath != "input.json" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if results[0].Snippet != "**Gophers** are burrowing rodents" {
+		t.Errorf("Unexpected snippet: %q", results[0].Snippet)
+	}
+}
+
+func TestStatsCmd(t *testing.T) {
+	resetRootCmd()
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	root := exporter.Root{
+		Source:      "stored.json",
+		RunSettings: exporter.RunSettings{Model: "models/gemini-2.5-flash"},
+		ChunkedPrompt: exporter.ChunkedPrompt{Chunks: []exporter.Chunk{
+			{Text: "Hello there", Role: exporter.RoleUser, TokenCount: 2},
+		}},
+	}
+	if err := (&exporter.SQLiteWriter{DBPath: dbPath}).Write(root); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"stats", "../example.json", dbPath, "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	var output struct {
+		Conversations []exporter.Stats
+		Total         exporter.StatsSummary
+	}
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatalf("Failed to decode output %q: %v", buf.String(), err)
+	}
+	if len(output.Conversations) != 2 || output.Conversations[1].Source != "stored.json" || output.Conversations[1].Tokens != 2 {
+		t.Errorf("Unexpected conversations: %+v", output.Conversations)
+	}
+	if output.Total.Conversations != 2 || output.Total.Models["models/gemini-2.5-flash"] != 1 {
+		t.Errorf("Unexpected total: %+v", output.Total)
+	}
+}
+
+func TestCostCmd(t *testing.T) {
+	resetRootCmd()
+
+	dir := t.TempDir()
+	pricesFile := filepath.Join(dir, "prices.yaml")
+	if err := os.WriteFile(pricesFile, []byte("currency: EUR\nmodels:\n  gemini-2.5-flash: {input: 1000000, output: 2000000}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputFile := filepath.Join(dir, "input.json")
+	inputJSON := `{
+		"runSettings": {"model": "models/gemini-2.5-flash"},
+		"chunkedPrompt": {"chunks": [
+			{"text": "Hello", "role": "user", "tokenCount": 2},
+			{"text": "Hi there", "role": "model", "tokenCount": 3}
+		]}
+	}`
+	if err := os.WriteFile(inputFile, []byte(inputJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(errOut)
+	rootCmd.SetArgs([]string{"cost", inputFile, "../example.json", "--prices", pricesFile, "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	var output struct {
+		Conversations []exporter.Cost
+		Total         exporter.Cost
+	}
+	if err := json.Unmarshal(out.Bytes(), &output); err != nil {
+		t.Fatalf("Failed to decode output %q: %v", out.String(), err)
+	}
+	if len(output.Conversations) != 2 || !output.Conversations[0].Priced || output.Conversations[1].Priced {
+		t.Errorf("Unexpected conversations: %+v", output.Conversations)
+	}
+	if output.Total.Total != 8 || output.Total.Currency != "EUR" {
+		t.Errorf("Unexpected total: %+v", output.Total)
+	}
+	if !strings.Contains(errOut.String(), "No price for model models/gemini-3-flash-preview") {
+		t.Errorf("Expected a warning about the unpriced model, got %q", errOut.String())
+	}
+}
+
+func TestNewWriter_Stdout(t *testing.T) {
+	defer func() { format = "txt" }()
+
+	format = "txt"
+	var buf bytes.Buffer
+	writer, err := newWriter("-", &buf)
+	if err != nil {
+		t.Fatalf("newWriter failed: %v", err)
+	}
+	if err := exporter.ExportReader(strings.NewReader(`{"chunkedPrompt": {"chunks": [{"text": "Piped"}]}}`), "-", writer); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+	if buf.String() != "Piped" {
+		t.Errorf("Output = %q, want %q", buf.String(), "Piped")
+	}
+
+	format = "sqlite"
+	if _, err := newWriter("-", &buf); err == nil {
+		t.Error("Expected error writing sqlite to stdout, got nil")
+	}
+}
+
+func TestChunkFilter(t *testing.T) {
+	defer func() { filterRoles, turns, match = nil, "", "" }()
+
+	if filter, err := chunkFilter(); err != nil || filter != nil {
+		t.Fatalf("Expected no filter without flags, got %v", err)
+	}
+
+	filterRoles, turns, match = []string{"model"}, "2-", "answer"
+	filter, err := chunkFilter()
+	if err != nil {
+		t.Fatalf("chunkFilter failed: %v", err)
+	}
+	if !filter(exporter.Chunk{Role: "model", Text: "an answer"}, exporter.Position{Turn: 2}) {
+		t.Error("Expected matching chunk to be kept")
+	}
+	if filter(exporter.Chunk{Role: "model", Text: "an answer"}, exporter.Position{Turn: 1}) {
+		t.Error("Expected chunk outside the turn range to be dropped")
+	}
+
+	match = "("
+	if _, err := chunkFilter(); err == nil {
+		t.Error("Expected error for invalid pattern, got nil")
+	}
+}
+
+func TestTranscriptFormat(t *testing.T) {
+	defer func() { importFormat = "" }()
+
+	tests := []struct {
+		flag     string
+		input    string
+		expected exporter.TranscriptFormat
+		wantErr  bool
+	}{
+		{input: "chat.txt", expected: exporter.TextTranscript},
+		{input: "chat.MD", expected: exporter.MarkdownTranscript},
+		{input: "-", expected: exporter.TextTranscript},
+		{flag: "md", input: "-", expected: exporter.MarkdownTranscript},
+		{flag: "text", input: "chat.md", expected: exporter.TextTranscript},
+		{flag: "html", input: "chat.html", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		importFormat = tt.flag
+		got, err := transcriptFormat(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("transcriptFormat(%q) with --format %q: expected error", tt.input, tt.flag)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("transcriptFormat(%q) with --format %q = %v, %v; want %v", tt.input, tt.flag, got, err, tt.expected)
+		}
+	}
+}
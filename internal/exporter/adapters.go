@@ -0,0 +1,479 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// InputFormat identifies the tool an input file was exported from.
+type InputFormat int
+
+const (
+	// AIStudioInput is an AI Studio prompt with a chunkedPrompt object.
+	AIStudioInput InputFormat = iota
+	// ChatGPTInput is the conversations.json of a ChatGPT data export: an
+	// array of conversations whose messages form a tree in "mapping".
+	ChatGPTInput
+	// ClaudeInput is the conversations.json of a Claude data export: an
+	// array of conversations with a "chat_messages" list.
+	ClaudeInput
+)
+
+func (f InputFormat) String() string {
+	switch f {
+	case ChatGPTInput:
+		return "ChatGPT"
+	case ClaudeInput:
+		return "Claude"
+	default:
+		return "AI Studio"
+	}
+}
+
+// sniffSize bounds how much of an input DetectFormat reads ahead.
+const sniffSize = 1 << 20
+
+// formatKeys are the top-level keys that tell the input formats apart.
+var formatKeys = map[string]InputFormat{
+	"chunkedPrompt": AIStudioInput,
+	"mapping":       ChatGPTInput,
+	"chat_messages": ClaudeInput,
+}
+
+// DetectFormat reads ahead in r to tell which tool it was exported from and
+// returns a reader that replays the input from the start. A ChatGPT or
+// Claude export may be an array of conversations or a single conversation
+// object. Inputs that cannot be told apart are treated as AI Studio prompts,
+// unless they go on beyond the part read ahead, which is an error: the key
+// that decides may come later.
+func DetectFormat(r io.Reader) (InputFormat, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffSize)
+	prefix, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return AIStudioInput, nil, fmt.Errorf("error reading input: %w", err)
+	}
+	format, ok := sniffFormat(bytes.NewReader(prefix))
+	if !ok && len(prefix) == sniffSize {
+		return AIStudioInput, nil, fmt.Errorf("error reading input: format could not be detected, no chunkedPrompt, mapping or chat_messages key in the first %d MiB", sniffSize>>20)
+	}
+	return format, br, nil
+}
+
+// sniffFormat scans the keys of the JSON object read from r, or of the first
+// element of an array of objects, for one of formatKeys. Values of other keys
+// are skipped without decoding them.
+func sniffFormat(r io.Reader) (InputFormat, bool) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return AIStudioInput, false
+	}
+	array := token == json.Delim('[')
+	if array {
+		if token, err = decoder.Token(); err != nil {
+			return AIStudioInput, false
+		}
+	}
+	if token != json.Delim('{') {
+		return AIStudioInput, false
+	}
+
+	key := scanKeys(decoder, func(key string) bool {
+		_, ok := formatKeys[key]
+		return ok
+	})
+	format, ok := formatKeys[key]
+	if array && format == AIStudioInput {
+		// AI Studio prompts are never wrapped in an array.
+		return AIStudioInput, false
+	}
+	return format, ok
+}
+
+// scanKeys reads the keys of the object opened before decoder's position
+// until match accepts one, which it returns, skipping the values of the
+// others. It returns "" if no key matches.
+func scanKeys(decoder *json.Decoder, match func(key string) bool) string {
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if key, ok := token.(string); ok && match(key) {
+			return key
+		}
+		var skipped json.RawMessage
+		if err := decoder.Decode(&skipped); err != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// isConversationExport reports whether the file at path is a ChatGPT or
+// Claude export.
+func isConversationExport(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	format, _, err := DetectFormat(f)
+	return err == nil && format != AIStudioInput
+}
+
+// Conversation is a single conversation of a ChatGPT or Claude export.
+type Conversation struct {
+	// ID is the conversation's ID in the export, or its position in the
+	// export, counting from 1, if it has none.
+	ID    string
+	Title string
+	// Root holds the conversation's messages. Its Source is the source of
+	// the export followed by the conversation ID.
+	Root Root
+}
+
+// ReadConversations decodes a ChatGPT or Claude export from r, detecting its
+// format, and passes its conversations to fn one at a time. source names
+// the input. It stops at the first error returned by fn.
+func ReadConversations(r io.Reader, source string, fn func(Conversation) error) error {
+	format, r, err := DetectFormat(r)
+	if err != nil {
+		return err
+	}
+	if format == AIStudioInput {
+		return errors.New("input is not a ChatGPT or Claude export")
+	}
+	return readConversations(r, format, source, fn)
+}
+
+func readConversations(r io.Reader, format InputFormat, source string, fn func(Conversation) error) error {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	array := startsWithArray(br)
+	decoder := json.NewDecoder(br)
+	index := 0
+	decodeOne := func() error {
+		index++
+		var conv Conversation
+		var err error
+		if format == ChatGPTInput {
+			var c chatGPTConversation
+			if err := decoder.Decode(&c); err != nil {
+				return fmt.Errorf("error parsing JSON: %w", err)
+			}
+			conv, err = c.conversation()
+		} else {
+			var c claudeConversation
+			if err := decoder.Decode(&c); err != nil {
+				return fmt.Errorf("error parsing JSON: %w", err)
+			}
+			conv = c.conversation()
+		}
+		if err != nil {
+			return err
+		}
+		if conv.ID == "" {
+			conv.ID = fmt.Sprint(index)
+		}
+		conv.Root.Source = source + ":" + conv.ID
+		return fn(conv)
+	}
+
+	if !array {
+		if err := decodeOne(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("error parsing JSON: %w", err)
+		}
+		for decoder.More() {
+			if err := decodeOne(); err != nil {
+				return err
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("error parsing JSON: %w", err)
+		}
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("error parsing JSON: unexpected data after top-level value")
+	}
+	return nil
+}
+
+// startsWithArray reports whether the first non-space byte of br opens an
+// array, consuming the white space before it.
+func startsWithArray(br *bufio.Reader) bool {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return false
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			br.UnreadByte()
+			return b == '['
+		}
+	}
+}
+
+// chatGPTConversation is a conversation of a ChatGPT export. Its messages
+// form a tree, with a branch for every edited prompt or regenerated answer;
+// current_node is the last message of the branch shown in ChatGPT.
+type chatGPTConversation struct {
+	ID               string                 `json:"id"`
+	ConversationID   string                 `json:"conversation_id"`
+	Title            string                 `json:"title"`
+	DefaultModelSlug string                 `json:"default_model_slug"`
+	CurrentNode      string                 `json:"current_node"`
+	Mapping          map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Message  *chatGPTMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	Content struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+		Language    string            `json:"language"`
+		Thoughts    []struct {
+			Summary string `json:"summary"`
+			Content string `json:"content"`
+		} `json:"thoughts"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug     string `json:"model_slug"`
+		Hidden        bool   `json:"is_visually_hidden_from_conversation"`
+		FinishDetails *struct {
+			Type string `json:"type"`
+		} `json:"finish_details"`
+	} `json:"metadata"`
+}
+
+// conversation converts the branch ending at current_node. User and
+// assistant messages become chunks, the model's thoughts become thought
+// chunks and a visible system message becomes the system instruction; tool
+// output, hidden messages and content other than text, code and thoughts
+// are left out.
+func (c chatGPTConversation) conversation() (Conversation, error) {
+	if c.Mapping == nil {
+		return Conversation{}, errors.New("error parsing ChatGPT export: conversation has no mapping")
+	}
+	conv := Conversation{ID: c.ConversationID, Title: c.Title}
+	if conv.ID == "" {
+		conv.ID = c.ID
+	}
+	root := &conv.Root
+	root.RunSettings.Model = c.DefaultModelSlug
+
+	for _, message := range c.branch() {
+		if message.Metadata.Hidden {
+			continue
+		}
+		text, thought := message.text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		switch message.Author.Role {
+		case "system":
+			root.SystemInstruction = SystemInstruction{Text: text}
+		case "user":
+			root.ChunkedPrompt.Chunks = append(root.ChunkedPrompt.Chunks, Chunk{Text: text, Role: RoleUser})
+		case "assistant":
+			chunk := Chunk{Text: text, Role: RoleModel, IsThought: thought}
+			if details := message.Metadata.FinishDetails; details != nil && !thought {
+				chunk.FinishReason = strings.ToUpper(details.Type)
+			}
+			if slug := message.Metadata.ModelSlug; slug != "" && root.RunSettings.Model == "" {
+				root.RunSettings.Model = slug
+			}
+			root.ChunkedPrompt.Chunks = append(root.ChunkedPrompt.Chunks, chunk)
+		}
+	}
+	return conv, nil
+}
+
+// branch returns the messages from the root of the tree to current_node, or
+// to the last reply of the latest branch if current_node is missing.
+func (c chatGPTConversation) branch() []chatGPTMessage {
+	id := c.CurrentNode
+	if _, ok := c.Mapping[id]; !ok {
+		id = c.latestLeaf()
+	}
+
+	var messages []chatGPTMessage
+	seen := make(map[string]bool)
+	for id != "" && !seen[id] {
+		seen[id] = true
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		if node.Message != nil {
+			messages = append(messages, *node.Message)
+		}
+		id = node.Parent
+	}
+	slices.Reverse(messages)
+	return messages
+}
+
+// latestLeaf follows the last child of each node from the root of the tree.
+func (c chatGPTConversation) latestLeaf() string {
+	var roots []string
+	for id, node := range c.Mapping {
+		if _, ok := c.Mapping[node.Parent]; !ok {
+			roots = append(roots, id)
+		}
+	}
+	if len(roots) == 0 {
+		return ""
+	}
+	slices.Sort(roots)
+
+	id := roots[0]
+	seen := make(map[string]bool)
+	for !seen[id] {
+		seen[id] = true
+		children := c.Mapping[id].Children
+		if len(children) == 0 {
+			break
+		}
+		id = children[len(children)-1]
+	}
+	return id
+}
+
+// text returns the text of the message and whether it is a thought.
+func (m chatGPTMessage) text() (string, bool) {
+	content := m.Content
+	switch content.ContentType {
+	case "text", "multimodal_text":
+		var parts []string
+		for _, raw := range content.Parts {
+			// Other parts are images and files, which the export refers
+			// to by ID only.
+			var part string
+			if json.Unmarshal(raw, &part) == nil && part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n"), false
+	case "code":
+		language := content.Language
+		if language == "unknown" {
+			language = ""
+		}
+		return "```" + language + "\n" + strings.TrimRight(content.Text, "\n") + "\n```", false
+	case "thoughts":
+		var thoughts []string
+		for _, thought := range content.Thoughts {
+			text := strings.TrimSpace(thought.Summary + "\n\n" + thought.Content)
+			if text != "" {
+				thoughts = append(thoughts, text)
+			}
+		}
+		return strings.Join(thoughts, "\n\n"), true
+	default:
+		return "", false
+	}
+}
+
+// claudeConversation is a conversation of a Claude export.
+type claudeConversation struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	Model        string          `json:"model"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	Sender  string `json:"sender"`
+	Text    string `json:"text"`
+	Content []struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"content"`
+	Attachments []struct {
+		FileName         string `json:"file_name"`
+		ExtractedContent string `json:"extracted_content"`
+	} `json:"attachments"`
+	Files []struct {
+		FileName string `json:"file_name"`
+	} `json:"files"`
+}
+
+// conversation converts the messages of the human and the assistant. Text
+// blocks become chunks and thinking blocks thought chunks; tool use is left
+// out. The text extracted from attached files is kept after the message,
+// labelled like the text format labels attachments, and other files are
+// listed by name.
+func (c claudeConversation) conversation() Conversation {
+	conv := Conversation{ID: c.UUID, Title: c.Name}
+	root := &conv.Root
+	root.RunSettings.Model = c.Model
+
+	for _, message := range c.ChatMessages {
+		role := RoleModel
+		if message.Sender == "human" {
+			role = RoleUser
+		}
+
+		var text []string
+		flush := func() {
+			joined := strings.Join(text, "\n\n")
+			if strings.TrimSpace(joined) != "" {
+				root.ChunkedPrompt.Chunks = append(root.ChunkedPrompt.Chunks, Chunk{Text: joined, Role: role})
+			}
+			text = nil
+		}
+
+		for _, block := range message.Content {
+			switch block.Type {
+			case "text":
+				text = append(text, block.Text)
+			case "thinking":
+				flush()
+				if strings.TrimSpace(block.Thinking) != "" {
+					root.ChunkedPrompt.Chunks = append(root.ChunkedPrompt.Chunks, Chunk{Text: block.Thinking, Role: role, IsThought: true})
+				}
+			}
+		}
+		if len(message.Content) == 0 {
+			text = append(text, message.Text)
+		}
+		for _, attachment := range message.Attachments {
+			file := "[File: " + attachment.FileName + "]"
+			if attachment.ExtractedContent != "" {
+				file += "\n" + attachment.ExtractedContent
+			}
+			text = append(text, file)
+		}
+		for _, file := range message.Files {
+			text = append(text, "[File: "+file.FileName+"]")
+		}
+		flush()
+	}
+	return conv
+}
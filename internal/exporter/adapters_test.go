@@ -0,0 +1,302 @@
+package exporter
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const chatGPTExport = `[{
+	"title": "Fix: a flaky test!",
+	"conversation_id": "6f1c2a9e-1234-5678-9abc-def012345678",
+	"default_model_slug": "gpt-4o",
+	"current_node": "answer-2",
+	"mapping": {
+		"root": {"id": "root", "message": null, "parent": null, "children": ["system"]},
+		"system": {"id": "system", "message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}, "parent": "root", "children": ["question"]},
+		"question": {"id": "question", "message": {"author": {"role": "user"}, "content": {"content_type": "multimodal_text", "parts": [{"content_type": "image_asset_pointer"}, "Why does it fail?"]}, "metadata": {}}, "parent": "system", "children": ["answer-1", "thoughts"]},
+		"answer-1": {"id": "answer-1", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["Regenerated away"]}, "metadata": {}}, "parent": "question", "children": []},
+		"thoughts": {"id": "thoughts", "message": {"author": {"role": "assistant"}, "content": {"content_type": "thoughts", "thoughts": [{"summary": "Timing", "content": "It sleeps."}]}, "metadata": {}}, "parent": "question", "children": ["recap"]},
+		"recap": {"id": "recap", "message": {"author": {"role": "assistant"}, "content": {"content_type": "reasoning_recap", "content": "Thought for 3s"}, "metadata": {}}, "parent": "thoughts", "children": ["code"]},
+		"code": {"id": "code", "message": {"author": {"role": "assistant"}, "content": {"content_type": "code", "language": "python", "text": "print(1)"}, "metadata": {}}, "parent": "recap", "children": ["output"]},
+		"output": {"id": "output", "message": {"author": {"role": "tool"}, "content": {"content_type": "execution_output", "text": "1"}, "metadata": {}}, "parent": "code", "children": ["answer-2"]},
+		"answer-2": {"id": "answer-2", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["It races."]}, "metadata": {"model_slug": "o3", "finish_details": {"type": "stop"}}}, "parent": "output", "children": []}
+	}
+}]`
+
+const claudeExport = `[{
+	"uuid": "0b3e7c55-aaaa-bbbb-cccc-111122223333",
+	"name": "Schema review",
+	"chat_messages": [
+		{"sender": "human", "text": "Review this", "content": [{"type": "text", "text": "Review this"}], "attachments": [{"file_name": "schema.sql", "extracted_content": "CREATE TABLE t (id int);"}], "files": [{"file_name": "diagram.png"}]},
+		{"sender": "assistant", "text": "", "content": [{"type": "thinking", "thinking": "Looks fine."}, {"type": "text", "text": "Add a primary key."}, {"type": "tool_use", "name": "search"}, {"type": "text", "text": "Done."}]},
+		{"sender": "human", "text": "Thanks", "content": []}
+	]
+}, {
+	"uuid": "c0ffee00-0000-0000-0000-000000000000",
+	"name": "",
+	"chat_messages": []
+}]`
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected InputFormat
+	}{
+		{"AI Studio", `{"runSettings": {"mapping": 1}, "chunkedPrompt": {"chunks": []}}`, AIStudioInput},
+		{"ChatGPT", chatGPTExport, ChatGPTInput},
+		{"ChatGPT conversation", `{"title": "t", "mapping": {}}`, ChatGPTInput},
+		{"Claude", claudeExport, ClaudeInput},
+		{"Empty array", `[]`, AIStudioInput},
+		{"Invalid", `not json`, AIStudioInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, r, err := DetectFormat(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("DetectFormat failed: %v", err)
+			}
+			if format != tt.expected {
+				t.Errorf("DetectFormat() = %v, want %v", format, tt.expected)
+			}
+			replayed, err := io.ReadAll(r)
+			if err != nil || string(replayed) != tt.input {
+				t.Errorf("Replayed input = %q (%v), want %q", replayed, err, tt.input)
+			}
+		})
+	}
+}
+
+func TestDetectFormat_Undecided(t *testing.T) {
+	// The key that tells the format comes after the part read ahead.
+	input := `[{"uuid": "a", "name": "` + strings.Repeat("x", sniffSize) + `", "chat_messages": []}]`
+	if _, _, err := DetectFormat(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "could not be detected") {
+		t.Errorf("Expected an undetected format error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportReader(strings.NewReader(input), "conversations.json", &TextWriter{Output: &buf}); err == nil {
+		t.Error("Expected ExportReader to fail, got nil")
+	}
+}
+
+func readAllConversations(t *testing.T, input string) []Conversation {
+	t.Helper()
+	var conversations []Conversation
+	err := ReadConversations(strings.NewReader(input), "conversations.json", func(conv Conversation) error {
+		conversations = append(conversations, conv)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadConversations failed: %v", err)
+	}
+	return conversations
+}
+
+func TestReadConversations_ChatGPT(t *testing.T) {
+	conversations := readAllConversations(t, chatGPTExport)
+	if len(conversations) != 1 {
+		t.Fatalf("Expected 1 conversation, got %d", len(conversations))
+	}
+	conv := conversations[0]
+
+	if conv.ID != "6f1c2a9e-1234-5678-9abc-def012345678" || conv.Title != "Fix: a flaky test!" {
+		t.Errorf("Unexpected conversation: %q, %q", conv.ID, conv.Title)
+	}
+	if conv.Root.Source != "conversations.json:"+conv.ID {
+		t.Errorf("Source = %q", conv.Root.Source)
+	}
+	if conv.Root.RunSettings.Model != "gpt-4o" || conv.Root.SystemInstruction.Content() != "" {
+		t.Errorf("Unexpected settings: %+v, %+v", conv.Root.RunSettings, conv.Root.SystemInstruction)
+	}
+
+	expected := []Chunk{
+		{Text: "Why does it fail?", Role: RoleUser},
+		{Text: "Timing\n\nIt sleeps.", Role: RoleModel, IsThought: true},
+		{Text: "```python\nprint(1)\n```", Role: RoleModel},
+		{Text: "It races.", Role: RoleModel, FinishReason: "STOP"},
+	}
+	if !reflect.DeepEqual(conv.Root.ChunkedPrompt.Chunks, expected) {
+		t.Errorf("Chunks = %+v, want %+v", conv.Root.ChunkedPrompt.Chunks, expected)
+	}
+}
+
+func TestReadConversations_ChatGPTWithoutCurrentNode(t *testing.T) {
+	input := `{"id": "x", "mapping": {
+		"a": {"message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["Hi"]}}, "children": ["b", "c"]},
+		"b": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["Old"]}}, "parent": "a"},
+		"c": {"message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["New"]}}, "parent": "a"}
+	}}`
+
+	conversations := readAllConversations(t, input)
+	if len(conversations) != 1 {
+		t.Fatalf("Expected 1 conversation, got %d", len(conversations))
+	}
+	if got := conversationOf(conversations[0].Root); !reflect.DeepEqual(got, []Chunk{{Role: RoleUser, Text: "Hi"}, {Role: RoleModel, Text: "New"}}) {
+		t.Errorf("Chunks = %+v", got)
+	}
+}
+
+func TestReadConversations_Claude(t *testing.T) {
+	conversations := readAllConversations(t, claudeExport)
+	if len(conversations) != 2 {
+		t.Fatalf("Expected 2 conversations, got %d", len(conversations))
+	}
+
+	expected := []Chunk{
+		{Text: "Review this\n\n[File: schema.sql]\nCREATE TABLE t (id int);\n\n[File: diagram.png]", Role: RoleUser},
+		{Text: "Looks fine.", Role: RoleModel, IsThought: true},
+		{Text: "Add a primary key.\n\nDone.", Role: RoleModel},
+		{Text: "Thanks", Role: RoleUser},
+	}
+	if !reflect.DeepEqual(conversations[0].Root.ChunkedPrompt.Chunks, expected) {
+		t.Errorf("Chunks = %+v, want %+v", conversations[0].Root.ChunkedPrompt.Chunks, expected)
+	}
+	if conversations[0].Title != "Schema review" || conversations[1].Root.Source != "conversations.json:c0ffee00-0000-0000-0000-000000000000" {
+		t.Errorf("Unexpected conversations: %+v", conversations)
+	}
+}
+
+func TestReadConversations_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"AI Studio", `{"chunkedPrompt": {"chunks": []}}`},
+		{"Truncated", `[{"uuid": "a", "chat_messages": []}, {"uuid": `},
+		{"Trailing data", `[{"uuid": "a", "chat_messages": []}] []`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReadConversations(strings.NewReader(tt.input), "in.json", func(Conversation) error { return nil })
+			if err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestExportBatch_Conversations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"chatgpt/conversations.json": chatGPTExport,
+		"claude/conversations.json":  claudeExport,
+	})
+
+	if !IsBatch([]string{filepath.Join(dir, "claude", "conversations.json")}) {
+		t.Error("Expected a Claude export to be exported as a batch")
+	}
+
+	inputs, err := ExpandInputs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	result := ExportBatch(inputs, func(input Input) (Writer, error) {
+		path := input.OutputPath(outDir, ".txt")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		return &TextWriter{OutputPath: path}, nil
+	})
+	if len(result.Failed) != 0 {
+		t.Fatalf("Failed = %+v", result.Failed)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "chatgpt", "conversations.json") + ":6f1c2a9e-1234-5678-9abc-def012345678",
+		filepath.Join(dir, "claude", "conversations.json") + ":0b3e7c55-aaaa-bbbb-cccc-111122223333",
+		filepath.Join(dir, "claude", "conversations.json") + ":c0ffee00-0000-0000-0000-000000000000",
+	}
+	if !reflect.DeepEqual(result.Succeeded, expected) {
+		t.Errorf("Succeeded = %v, want %v", result.Succeeded, expected)
+	}
+
+	files := map[string]string{
+		"chatgpt/conversations/fix-a-flaky-test-6f1c2a9e.txt": "Why does it fail?\n---\n```python\nprint(1)\n```\n---\nIt races.",
+		"claude/conversations/schema-review-0b3e7c55.txt":     "Review this\n\n[File: schema.sql]\nCREATE TABLE t (id int);\n\n[File: diagram.png]\n---\nAdd a primary key.\n\nDone.\n---\nThanks",
+		"claude/conversations/c0ffee00.txt":                   "",
+	}
+	for path, want := range files {
+		content, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Errorf("Expected %s: %v", path, err)
+			continue
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+}
+
+func TestExportReader_ConversationsToSQLite(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := ExportReader(strings.NewReader(claudeExport), "conversations.json", writer); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	if err := db.Model(&ConversationRecord{}).Order("id").Pluck("source_path", &sources).Error; err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"conversations.json:0b3e7c55-aaaa-bbbb-cccc-111122223333",
+		"conversations.json:c0ffee00-0000-0000-0000-000000000000",
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Conversations = %v, want %v", sources, expected)
+	}
+
+	var count int64
+	if err := db.Model(&MessageRecord{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	// The thought is excluded by default.
+	if count != 3 {
+		t.Errorf("Expected 3 messages, got %d", count)
+	}
+}
+
+func TestExpandInputs_ZipConversations(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "chatgpt.zip")
+	writeZip(t, zipPath, []struct{ name, content string }{
+		{"conversations.json", chatGPTExport},
+		{"chat.html", "<html></html>"},
+		{"user.json", `{"id": "user"}`},
+	})
+
+	inputs, err := ExpandInputs([]string{zipPath})
+	if err != nil {
+		t.Fatalf("ExpandInputs failed: %v", err)
+	}
+	expected := []Input{{Path: zipPath, Entry: "conversations.json", Rel: filepath.Join("chatgpt", "conversations.json")}}
+	if !reflect.DeepEqual(inputs, expected) {
+		t.Fatalf("ExpandInputs() = %+v, want %+v", inputs, expected)
+	}
+
+	var outputs []string
+	result := ExportBatch(inputs, func(input Input) (Writer, error) {
+		outputs = append(outputs, input.OutputPath("out", ".md"))
+		return &MarkdownWriter{Output: io.Discard}, nil
+	})
+	if len(result.Succeeded) != 1 || result.Succeeded[0] != zipPath+":conversations.json:6f1c2a9e-1234-5678-9abc-def012345678" {
+		t.Errorf("Succeeded = %v", result.Succeeded)
+	}
+	if want := []string{filepath.Join("out", "chatgpt", "conversations", "fix-a-flaky-test-6f1c2a9e.md")}; !reflect.DeepEqual(outputs, want) {
+		t.Errorf("Outputs = %v, want %v", outputs, want)
+	}
+}
@@ -0,0 +1,255 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestName is the file in an asset directory that lists its assets.
+const ManifestName = "manifest.json"
+
+// Asset describes an attachment saved to or referenced from an asset
+// directory.
+type Asset struct {
+	// Kind is the field the attachment was found in, e.g. "inlineImage".
+	Kind string `json:"kind"`
+	// Source is the input the attachment was found in.
+	Source   string `json:"source,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	// Path is the file holding an inline attachment, relative to the asset
+	// directory. It is named after the SHA-256 of the content, so equal
+	// attachments share a file.
+	Path   string `json:"path,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int    `json:"size,omitempty"`
+	// DriveID and URL identify a Drive attachment, which is not fetched.
+	DriveID string `json:"driveId,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// IsImage reports whether the asset is an image.
+func (a Asset) IsImage() bool {
+	return a.Kind == "inlineImage" || a.Kind == "driveImage" || strings.HasPrefix(a.MimeType, "image/")
+}
+
+// AssetStore saves the inline attachments of exported chunks to a directory
+// and lists them, together with the Drive attachments that cannot be fetched
+// offline, in its manifest.
+type AssetStore struct {
+	Dir string
+
+	assets []Asset
+	index  map[string]bool
+}
+
+// NewAssetStore creates the asset directory dir if needed and loads its
+// manifest, if any, so that repeated exports extend it.
+func NewAssetStore(dir string) (*AssetStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating asset directory: %w", err)
+	}
+	s := &AssetStore{Dir: dir, index: make(map[string]bool)}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading asset manifest: %w", err)
+	}
+	var assets []Asset
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, fmt.Errorf("error reading asset manifest: %w", err)
+	}
+	for _, asset := range assets {
+		s.add(asset)
+	}
+	return s, nil
+}
+
+// Assets returns the assets in the manifest.
+func (s *AssetStore) Assets() []Asset {
+	return s.assets
+}
+
+// Save writes an inline attachment found in source to the asset directory,
+// unless a file with the same content exists, or records a Drive attachment,
+// and returns its manifest entry.
+func (s *AssetStore) Save(source string, attachment Attachment) (Asset, error) {
+	asset, data := newAsset(source, attachment)
+	return asset, s.save(asset, data)
+}
+
+// save writes data, the content of asset, unless its file exists, and
+// records asset.
+func (s *AssetStore) save(asset Asset, data []byte) error {
+	if asset.Path != "" {
+		path := filepath.Join(s.Dir, asset.Path)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := writeOutput(nil, path, Overwrite, data); err != nil {
+				return err
+			}
+		}
+	}
+	s.add(asset)
+	return nil
+}
+
+// Link returns the reference to asset from a document written to output: a
+// path relative to the directory of output, or the Drive URL.
+func (s *AssetStore) Link(asset Asset, output string) string {
+	if asset.Path == "" {
+		return asset.URL
+	}
+	path := filepath.Join(s.Dir, asset.Path)
+	dir := "."
+	if output != "" {
+		dir = filepath.Dir(output)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		if base, err := filepath.Abs(dir); err == nil {
+			if rel, err := filepath.Rel(base, abs); err == nil {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// WriteManifest writes the manifest listing every asset saved or loaded.
+func (s *AssetStore) WriteManifest() error {
+	assets := s.assets
+	if assets == nil {
+		assets = []Asset{}
+	}
+	data, err := json.MarshalIndent(assets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding asset manifest: %w", err)
+	}
+	return writeOutput(nil, filepath.Join(s.Dir, ManifestName), Overwrite, append(data, '\n'))
+}
+
+// add records asset unless the manifest already lists it for the same
+// source.
+func (s *AssetStore) add(asset Asset) {
+	key := asset.Source + "\x00" + asset.SHA256 + "\x00" + asset.DriveID
+	if s.index[key] {
+		return
+	}
+	s.index[key] = true
+	s.assets = append(s.assets, asset)
+}
+
+// newAsset returns the manifest entry of attachment and, for an inline
+// attachment, its content.
+func newAsset(source string, attachment Attachment) (Asset, []byte) {
+	asset := Asset{Kind: attachment.Kind, Source: source}
+	if attachment.Drive != nil {
+		asset.DriveID = attachment.Drive.ID
+		asset.URL = "https://drive.google.com/file/d/" + attachment.Drive.ID + "/view"
+		return asset, nil
+	}
+
+	data := attachment.Inline.Data
+	sum := sha256.Sum256(data)
+	asset.MimeType = attachment.Inline.MimeType
+	asset.SHA256 = hex.EncodeToString(sum[:])
+	asset.Size = len(data)
+	asset.Path = asset.SHA256[:32] + assetExtension(asset.MimeType)
+	return asset, data
+}
+
+// commonExtensions fixes the extension of common types, for which
+// mime.ExtensionsByType may return several in system-dependent order.
+var commonExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+	"audio/mpeg":      ".mp3",
+	"audio/wav":       ".wav",
+	"video/mp4":       ".mp4",
+}
+
+func assetExtension(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ".bin"
+	}
+	if ext, ok := commonExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// saveAttachments saves the attachments of chunk to assets and returns
+// their manifest entries.
+func saveAttachments(assets *AssetStore, source string, chunk Chunk) ([]Asset, error) {
+	var saved []Asset
+	for _, attachment := range chunk.Attachments() {
+		asset, err := assets.Save(source, attachment)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, asset)
+	}
+	return saved, nil
+}
+
+// chunkMarkdown returns the text of chunk followed by Markdown links from a
+// document written to output to its attachments, saving them to assets if it
+// is set.
+func chunkMarkdown(assets *AssetStore, source, output string, chunk Chunk) (string, error) {
+	text := strings.TrimRight(chunk.Content(), "\n")
+	if assets == nil {
+		return text, nil
+	}
+	saved, err := saveAttachments(assets, source, chunk)
+	if err != nil {
+		return "", err
+	}
+
+	var links []string
+	for _, asset := range saved {
+		link := "<" + assets.Link(asset, output) + ">"
+		switch {
+		case asset.Path == "" && asset.IsImage():
+			links = append(links, "[Drive image]("+link+")")
+		case asset.Path == "":
+			links = append(links, "[Drive file]("+link+")")
+		case asset.IsImage():
+			links = append(links, "![Image]("+link+")")
+		default:
+			links = append(links, "[File]("+link+")")
+		}
+	}
+	if len(links) == 0 {
+		return text, nil
+	}
+	if text != "" {
+		text += "\n\n"
+	}
+	return text + strings.Join(links, "\n"), nil
+}
+
+// outputName returns the path of the file a writer produces, or "" when it
+// writes to out instead.
+func outputName(path string, out io.Writer) string {
+	if out != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,254 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// attachmentJSON has an image with a caption, a file attached to a part and
+// a Drive document without text.
+const attachmentJSON = `{
+	"chunkedPrompt": {
+		"chunks": [
+			{"text": "Look at this", "role": "user", "inlineImage": {"mimeType": "image/png", "data": "iVBORw0KGgo="}},
+			{"role": "user", "parts": [{"text": "And this"}, {"inlineData": {"mimeType": "application/pdf", "data": "JVBERi0xLjQ="}}]},
+			{"text": "", "role": "user", "driveDocument": {"id": "1AbC"}},
+			{"text": "Nice", "role": "model"}
+		]
+	}
+}`
+
+const (
+	pngAsset = "4c4b6a3be1314ab86138bef4314dde02.png"
+	pdfAsset = "e16fa5d9b51928755db85b917f0297ba.pdf"
+	driveURL = "https://drive.google.com/file/d/1AbC/view"
+)
+
+func attachmentRoot(t *testing.T) Root {
+	t.Helper()
+	var root Root
+	if err := json.Unmarshal([]byte(attachmentJSON), &root); err != nil {
+		t.Fatal(err)
+	}
+	root.Source = "prompt.json"
+	return root
+}
+
+func TestAssetStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "assets")
+	store, err := NewAssetStore(dir)
+	if err != nil {
+		t.Fatalf("NewAssetStore failed: %v", err)
+	}
+
+	for _, chunk := range attachmentRoot(t).ChunkedPrompt.Chunks {
+		if _, err := saveAttachments(store, "prompt.json", chunk); err != nil {
+			t.Fatalf("saveAttachments failed: %v", err)
+		}
+	}
+	if err := store.WriteManifest(); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, pngAsset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "\x89PNG\r\n\x1a\n" {
+		t.Errorf("Image = %q, want the decoded PNG signature", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, pdfAsset)); err != nil {
+		t.Errorf("Expected the PDF to be saved: %v", err)
+	}
+
+	// Reopening the directory keeps the manifest without duplicating it.
+	store, err = NewAssetStore(dir)
+	if err != nil {
+		t.Fatalf("NewAssetStore failed: %v", err)
+	}
+	if _, err := saveAttachments(store, "prompt.json", attachmentRoot(t).ChunkedPrompt.Chunks[2]); err != nil {
+		t.Fatalf("saveAttachments failed: %v", err)
+	}
+
+	assets := store.Assets()
+	if len(assets) != 3 {
+		t.Fatalf("Got %d assets, want 3: %+v", len(assets), assets)
+	}
+	drive := assets[2]
+	if drive.Kind != "driveDocument" || drive.DriveID != "1AbC" || drive.URL != driveURL || drive.Path != "" {
+		t.Errorf("Drive asset = %+v", drive)
+	}
+	if assets[0].Source != "prompt.json" || assets[0].MimeType != "image/png" || assets[0].Size != 8 {
+		t.Errorf("Image asset = %+v", assets[0])
+	}
+}
+
+func TestAssetExtension(t *testing.T) {
+	tests := map[string]string{
+		"image/png":                 ".png",
+		"image/jpeg":                ".jpg",
+		"application/pdf":           ".pdf",
+		"text/plain; charset=utf-8": ".txt",
+		"application/x-unknown":     ".bin",
+		"":                          ".bin",
+	}
+	for mimeType, expected := range tests {
+		if ext := assetExtension(mimeType); ext != expected {
+			t.Errorf("assetExtension(%q) = %q, want %q", mimeType, ext, expected)
+		}
+	}
+}
+
+func TestWriters_Assets(t *testing.T) {
+	tests := []struct {
+		name     string
+		writer   func(path string, assets *AssetStore) Writer
+		expected []string
+	}{
+		{
+			name: "text",
+			writer: func(path string, assets *AssetStore) Writer {
+				return &TextWriter{OutputPath: path, Assets: assets}
+			},
+			expected: []string{
+				"Look at this\n[Image: assets/" + pngAsset + "]\n---\n",
+				"And this\n[File: assets/" + pdfAsset + "]\n---\n",
+				"[Drive file: " + driveURL + "]\n---\nNice",
+			},
+		},
+		{
+			name: "markdown",
+			writer: func(path string, assets *AssetStore) Writer {
+				return &MarkdownWriter{OutputPath: path, Assets: assets}
+			},
+			expected: []string{
+				"Look at this\n\n![Image](<assets/" + pngAsset + ">)\n",
+				"And this\n\n[File](<assets/" + pdfAsset + ">)\n",
+				"[Drive file](<" + driveURL + ">)\n",
+			},
+		},
+		{
+			name: "html",
+			writer: func(path string, assets *AssetStore) Writer {
+				return &HTMLWriter{OutputPath: path, Assets: assets}
+			},
+			expected: []string{
+				`<img src="data:image/png;base64,`,
+				`<a href="data:application/pdf;base64,`,
+				`<a href="` + driveURL + `">Drive file</a>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewAssetStore(filepath.Join(dir, "assets"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputPath := filepath.Join(dir, "output")
+			if err := tt.writer(outputPath, store).Write(attachmentRoot(t)); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			result, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(result), expected) {
+					t.Errorf("Expected output to contain %q, got:\n%s", expected, result)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, "assets", pngAsset)); err != nil {
+				t.Errorf("Expected the image to be saved: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriters_NoAssets(t *testing.T) {
+	// Without an asset store attachments are left out as before.
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+	writer := &TextWriter{OutputPath: outputPath}
+	if err := writer.Write(attachmentRoot(t)); err != nil {
+		t.Fatalf("TextWriter.Write failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Look at this\n---\nAnd this\n---\nNice"; string(result) != expected {
+		t.Errorf("Result = %q, want %q", string(result), expected)
+	}
+}
+
+func TestSQLiteWriter_Attachments(t *testing.T) {
+	tests := []struct {
+		name  string
+		blobs bool
+		dir   bool
+	}{
+		{name: "references"},
+		{name: "blobs", blobs: true},
+		{name: "assets", dir: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dbPath := filepath.Join(dir, "test.db")
+			writer := &SQLiteWriter{DBPath: dbPath, Blobs: tt.blobs}
+			if tt.dir {
+				store, err := NewAssetStore(filepath.Join(dir, "assets"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				writer.Assets = store
+			}
+
+			// Importing twice must not duplicate the attachments.
+			for i := 0; i < 2; i++ {
+				if err := writer.Write(attachmentRoot(t)); err != nil {
+					t.Fatalf("SQLiteWriter.Write failed: %v", err)
+				}
+			}
+
+			db, err := openDB(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDB(db)
+
+			var attachments []AttachmentRecord
+			if err := db.Order("ordinal, id").Find(&attachments).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(attachments) != 3 {
+				t.Fatalf("Got %d attachments, want 3: %+v", len(attachments), attachments)
+			}
+
+			image := attachments[0]
+			if image.Ordinal != 0 || image.Kind != "inlineImage" || image.MimeType != "image/png" || image.Size != 8 {
+				t.Errorf("Image = %+v", image)
+			}
+			if len(image.SHA256) != 64 || !strings.HasPrefix(pngAsset, image.SHA256[:32]) {
+				t.Errorf("SHA256 = %q, want the hash of the image", image.SHA256)
+			}
+			if hasData := len(image.Data) > 0; hasData != tt.blobs {
+				t.Errorf("Stored data = %v, want %v", hasData, tt.blobs)
+			}
+			if hasPath := image.Path == pngAsset; hasPath != tt.dir {
+				t.Errorf("Path = %q, want the asset file only with an asset directory", image.Path)
+			}
+			if drive := attachments[2]; drive.Ordinal != 2 || drive.DriveID != "1AbC" || drive.SHA256 != "" {
+				t.Errorf("Drive attachment = %+v", drive)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Input is a prompt file selected for a batch export.
+type Input struct {
+	// Path is the file to read.
+	Path string
+	// Entry is the name of the prompt inside the zip archive at Path, if any.
+	Entry string
+	// Conversation is the ID of a conversation inside the ChatGPT or Claude
+	// export at Path, if any.
+	Conversation string
+	// Rel is the path relative to the directory or glob it was found
+	// through, used to name per-file outputs.
+	Rel string
+}
+
+// Source returns a name identifying the input, e.g. "takeout.zip:prompt".
+func (in Input) Source() string {
+	source := in.Path
+	if in.Entry != "" {
+		source += ":" + in.Entry
+	}
+	if in.Conversation != "" {
+		source += ":" + in.Conversation
+	}
+	return source
+}
+
+// Open opens the input for reading. The input of a conversation is the whole
+// export it is part of.
+func (in Input) Open() (io.ReadCloser, error) {
+	if in.Entry != "" {
+		return openZipEntry(in.Path, in.Entry)
+	}
+	f, err := os.Open(in.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading input file: %w", err)
+	}
+	return f, nil
+}
+
+// BatchFailure records an input that could not be exported.
+type BatchFailure struct {
+	Path string
+	Err  error
+}
+
+// BatchResult summarizes a batch export.
+type BatchResult struct {
+	Succeeded []string
+	Failed    []BatchFailure
+}
+
+// ExpandInputs resolves files, directories and glob patterns into the list of
+// input files. Directories are walked recursively for files with a .json
+// extension, and for files without an extension, which is how AI Studio
+// saves prompts to Drive, that start like a prompt; other files, such as a
+// README, and hidden files and directories are skipped. Explicitly named
+// files are always included. Zip archives, such as Google Takeout exports, are
+// expanded into the prompts they contain. ChatGPT and Claude exports are
+// selected like prompts; ExportBatch exports each of their conversations.
+func ExpandInputs(args []string) ([]Input, error) {
+	var inputs []Input
+	seen := make(map[string]bool)
+	add := func(path, rel string) error {
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+
+		if !isZip(path) {
+			inputs = append(inputs, Input{Path: path, Rel: rel})
+			return nil
+		}
+		entries, err := zipInputs(path)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(rel)
+		for _, entry := range entries {
+			entry.Rel = filepath.Join(dir, entry.Rel)
+			inputs = append(inputs, entry)
+		}
+		return nil
+	}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		switch {
+		case err == nil && info.IsDir():
+			files, err := walkPromptFiles(arg)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				rel, err := filepath.Rel(arg, file)
+				if err != nil {
+					return nil, fmt.Errorf("error resolving input %s: %w", file, err)
+				}
+				if err := add(file, rel); err != nil {
+					return nil, err
+				}
+			}
+		case err == nil:
+			if err := add(arg, filepath.Base(arg)); err != nil {
+				return nil, err
+			}
+		case isGlob(arg):
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && !info.IsDir() {
+					if err := add(match, filepath.Base(match)); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("error reading input: %w", err)
+		}
+	}
+
+	return inputs, nil
+}
+
+// IsBatch reports whether args select anything other than a single prompt
+// file. A ChatGPT or Claude export counts as a batch of its conversations.
+func IsBatch(args []string) bool {
+	if len(args) != 1 {
+		return true
+	}
+	info, err := os.Stat(args[0])
+	if err != nil {
+		return isGlob(args[0])
+	}
+	return info.IsDir() || isZip(args[0]) || isConversationExport(args[0])
+}
+
+// ExportBatch exports every input with the writer returned by newWriter.
+// Failures are collected instead of aborting the batch.
+func ExportBatch(inputs []Input, newWriter func(Input) (Writer, error)) BatchResult {
+	return ExportBatchContext(context.Background(), inputs, newWriter)
+}
+
+// ExportBatchContext is like ExportBatch but stops when ctx is cancelled.
+// The input being exported at that point is recorded as failed and the
+// remaining inputs are not attempted.
+//
+// Each conversation of a ChatGPT or Claude export is exported as an input of
+// its own, with the conversation ID set and a Rel naming it after its title
+// inside a directory named after the export.
+func ExportBatchContext(ctx context.Context, inputs []Input, newWriter func(Input) (Writer, error)) BatchResult {
+	var result BatchResult
+	for _, input := range inputs {
+		if ctx.Err() != nil {
+			break
+		}
+		exportInput(ctx, input, newWriter, &result)
+	}
+	return result
+}
+
+func (r *BatchResult) add(input Input, err error) {
+	if err != nil {
+		r.Failed = append(r.Failed, BatchFailure{Path: input.Source(), Err: err})
+		return
+	}
+	r.Succeeded = append(r.Succeeded, input.Source())
+}
+
+func exportInput(ctx context.Context, input Input, newWriter func(Input) (Writer, error), result *BatchResult) {
+	rc, err := input.Open()
+	if err != nil {
+		result.add(input, err)
+		return
+	}
+	defer rc.Close()
+
+	format, r, err := DetectFormat(rc)
+	if err != nil {
+		result.add(input, err)
+		return
+	}
+	if format == AIStudioInput {
+		writer, err := newWriter(input)
+		if err == nil {
+			err = ExportStreamContext(ctx, r, input.Source(), streamWriter(writer))
+		}
+		result.add(input, err)
+		return
+	}
+
+	err = readConversations(r, format, input.Source(), func(conv Conversation) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		conversation := input.conversation(conv)
+		writer, err := newWriter(conversation)
+		if err == nil {
+			err = writeDocument(ctx, streamWriter(writer), conv.Root)
+		}
+		result.add(conversation, err)
+		return ctx.Err()
+	})
+	if err != nil && ctx.Err() == nil {
+		result.add(input, err)
+	}
+}
+
+// conversation returns the input of a conversation of the export in.
+func (in Input) conversation(conv Conversation) Input {
+	dir := strings.TrimSuffix(in.Rel, filepath.Ext(in.Rel))
+	in.Conversation = conv.ID
+	in.Rel = filepath.Join(dir, conversationName(conv))
+	return in
+}
+
+// conversationName names the output of a conversation after its title and
+// the start of its ID, e.g. "fixing-a-flaky-test-6f1c2a9e".
+func conversationName(conv Conversation) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(conv.Title) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case sb.Len() > 0 && !strings.HasSuffix(sb.String(), "-"):
+			sb.WriteByte('-')
+		}
+		if sb.Len() >= 60 {
+			break
+		}
+	}
+	name := strings.Trim(sb.String(), "-")
+
+	id := conversationIDPattern.ReplaceAllString(conv.ID, "")
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	if name == "" {
+		return id
+	}
+	if id == "" {
+		return name
+	}
+	return name + "-" + id
+}
+
+var conversationIDPattern = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// OutputPath returns the per-file output path for input inside dir, with the
+// input extension replaced by ext.
+func (in Input) OutputPath(dir, ext string) string {
+	rel := strings.TrimSuffix(in.Rel, filepath.Ext(in.Rel))
+	return filepath.Join(dir, rel+ext)
+}
+
+func walkPromptFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && isPromptFileName(d.Name()) && (filepath.Ext(path) != "" || isPromptFile(path)) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading input directory: %w", err)
+	}
+
+	return files, nil
+}
+
+func isPromptFileName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".json" || ext == ".zip" || ext == ""
+}
+
+// isPromptFile reports whether the file at path starts like an AI Studio
+// prompt or a ChatGPT or Claude export, reading no more than DetectFormat
+// reads ahead. A JSON document whose format is not decided in that part is
+// reported as a prompt, so that exporting it reports the error.
+func isPromptFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	prefix, err := io.ReadAll(io.LimitReader(f, sniffSize))
+	if err != nil {
+		return false
+	}
+	if _, ok := sniffFormat(bytes.NewReader(prefix)); ok {
+		return true
+	}
+	trimmed := bytes.TrimLeft(prefix, " \t\r\n")
+	return len(prefix) == sniffSize && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+func isGlob(arg string) bool {
+	return strings.ContainsAny(arg, "*?[")
+}
@@ -0,0 +1,175 @@
+package exporter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.json":            "{}",
+		"notes.txt":         "skip me",
+		"sub/b.JSON":        "{}",
+		"sub/drive-prompt":  `{"chunkedPrompt": {}}`,
+		"sub/README":        "# Prompts",
+		"Makefile":          "all:",
+		"empty":             "{}",
+		".hidden/c.json":    "{}",
+		"sub/.d.json":       "{}",
+		"other/explicit.md": "{}",
+	})
+
+	inputs, err := ExpandInputs([]string{
+		dir,
+		filepath.Join(dir, "other", "explicit.md"),
+		filepath.Join(dir, "*.json"),
+	})
+	if err != nil {
+		t.Fatalf("ExpandInputs failed: %v", err)
+	}
+
+	expected := []Input{
+		{Path: filepath.Join(dir, "a.json"), Rel: "a.json"},
+		{Path: filepath.Join(dir, "sub", "b.JSON"), Rel: filepath.Join("sub", "b.JSON")},
+		{Path: filepath.Join(dir, "sub", "drive-prompt"), Rel: filepath.Join("sub", "drive-prompt")},
+		{Path: filepath.Join(dir, "other", "explicit.md"), Rel: "explicit.md"},
+	}
+	if !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("ExpandInputs() = %+v, want %+v", inputs, expected)
+	}
+}
+
+func TestExpandInputs_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := ExpandInputs([]string{filepath.Join(dir, "missing.json")}); err == nil {
+		t.Error("Expected error for missing file, got nil")
+	}
+	if _, err := ExpandInputs([]string{filepath.Join(dir, "*.json")}); err == nil {
+		t.Error("Expected error for glob without matches, got nil")
+	}
+}
+
+func TestIsBatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.json")
+	writeFiles(t, dir, map[string]string{"a.json": "{}"})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{"Single file", []string{file}, false},
+		{"Missing file", []string{filepath.Join(dir, "missing.json")}, false},
+		{"Directory", []string{dir}, true},
+		{"Glob", []string{filepath.Join(dir, "*.json")}, true},
+		{"Several files", []string{file, file}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBatch(tt.args); got != tt.expected {
+				t.Errorf("IsBatch() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExportBatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"good.json":  `{"chunkedPrompt": {"chunks": [{"text": "Good"}]}}`,
+		"bad.json":   "invalid json",
+		"other.json": `{"chunkedPrompt": {"chunks": [{"text": "Other"}]}}`,
+	})
+
+	inputs, err := ExpandInputs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	result := ExportBatch(inputs, func(input Input) (Writer, error) {
+		if input.Rel == "other.json" {
+			return nil, errors.New("no writer")
+		}
+		return &TextWriter{OutputPath: input.OutputPath(outDir, ".txt")}, nil
+	})
+
+	if !reflect.DeepEqual(result.Succeeded, []string{filepath.Join(dir, "good.json")}) {
+		t.Errorf("Succeeded = %v", result.Succeeded)
+	}
+	if len(result.Failed) != 2 || result.Failed[0].Path != filepath.Join(dir, "bad.json") || result.Failed[1].Err == nil {
+		t.Errorf("Failed = %+v", result.Failed)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outDir, "good.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "Good" {
+		t.Errorf("Output = %q, want %q", content, "Good")
+	}
+}
+
+func TestExportBatch_SkipsNonPrompts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"prompt":  `{"runSettings": {}, "chunkedPrompt": {"chunks": [{"text": "Hi"}]}}`,
+		"README":  "Prompts saved from AI Studio.",
+		"LICENSE": "MIT",
+	})
+
+	inputs, err := ExpandInputs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	result := ExportBatch(inputs, func(input Input) (Writer, error) {
+		return &MarkdownWriter{OutputPath: input.OutputPath(outDir, ".md")}, nil
+	})
+	if len(result.Failed) > 0 || !reflect.DeepEqual(result.Succeeded, []string{filepath.Join(dir, "prompt")}) {
+		t.Errorf("Result = %+v, want only the prompt exported", result)
+	}
+
+	// A file named explicitly is exported even if it is not a prompt.
+	inputs, err = ExpandInputs([]string{filepath.Join(dir, "README")})
+	if err != nil || len(inputs) != 1 {
+		t.Errorf("ExpandInputs() = %+v, %v, want the README", inputs, err)
+	}
+}
+
+func TestInputOutputPath(t *testing.T) {
+	tests := []struct {
+		rel      string
+		expected string
+	}{
+		{"a.json", filepath.Join("out", "a.md")},
+		{filepath.Join("sub", "prompt"), filepath.Join("out", "sub", "prompt.md")},
+	}
+
+	for _, tt := range tests {
+		if got := (Input{Rel: tt.rel}).OutputPath("out", ".md"); got != tt.expected {
+			t.Errorf("OutputPath(%q) = %q, want %q", tt.rel, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CodeIndexName is the file in a code extraction directory that maps the
+// extracted files back to the turns they come from.
+const CodeIndexName = "index.json"
+
+// CodeBlock is a fenced code block found in Markdown text.
+type CodeBlock struct {
+	// Language is the first word of the fence's info string, e.g. "go".
+	Language string
+	// Filename is the file name hinted at by the info string, a comment on
+	// the first line of the code or a line right before the fence.
+	Filename string
+	Code     string
+}
+
+// CodeFile describes a file written by CodeWriter.
+type CodeFile struct {
+	// Path is the file relative to the output directory.
+	Path string `json:"path"`
+	// Turn is the turn the code comes from, numbered like for TurnFilter.
+	Turn int `json:"turn"`
+	// Block is the 1-based number of the code block within its turn.
+	Block    int    `json:"block"`
+	Language string `json:"language,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Lines    int    `json:"lines"`
+}
+
+// codeIndex is the content of the CodeIndexName file.
+type codeIndex struct {
+	Source string     `json:"source,omitempty"`
+	Files  []CodeFile `json:"files"`
+}
+
+// CodeWriter writes the fenced code blocks of the model's turns to files in
+// OutputDir, one directory per turn, e.g. "turn-004/main.go", and lists them
+// in an index. Thoughts and user turns are skipped. Each conversation
+// replaces the index of the one before; see ExtractCode for exports with
+// many conversations.
+type CodeWriter struct {
+	OutputDir string
+	// Clobber selects what happens when a file already exists.
+	Clobber ClobberMode
+	// Files lists the files written by the last export.
+	Files []CodeFile
+
+	source string
+	turn   int
+	role   string
+	text   strings.Builder
+	blocks []codeFileBlock
+}
+
+// codeFileBlock is a code block waiting to be written at the end of the
+// export.
+type codeFileBlock struct {
+	file CodeFile
+	code string
+}
+
+// Write writes the code blocks of root.
+func (w *CodeWriter) Write(root Root) error {
+	return w.WriteContext(context.Background(), root)
+}
+
+// WriteContext is like Write but stops when ctx is cancelled, writing no
+// files.
+func (w *CodeWriter) WriteContext(ctx context.Context, root Root) error {
+	return writeDocument(ctx, w, root)
+}
+
+// Begin starts collecting the code blocks of the conversation.
+func (w *CodeWriter) Begin(meta Root) error {
+	w.source = meta.Source
+	w.turn, w.role = 0, ""
+	w.text.Reset()
+	w.blocks = nil
+	w.Files = nil
+	return nil
+}
+
+// WriteChunk adds the text of a model chunk to its turn. The code blocks of
+// a turn are extracted once it is complete, so blocks may span chunks.
+func (w *CodeWriter) WriteChunk(chunk Chunk) error {
+	if w.turn == 0 || chunk.Role != w.role {
+		w.endTurn()
+		w.turn++
+		w.role = chunk.Role
+	}
+	if chunk.Role == RoleUser {
+		return nil
+	}
+
+	for _, piece := range contentPieces(chunk, false) {
+		if piece.IsThought {
+			continue
+		}
+		if text := w.text.String(); text != "" && !strings.HasSuffix(text, "\n") {
+			w.text.WriteString("\n")
+		}
+		w.text.WriteString(piece.Content())
+	}
+	return nil
+}
+
+// endTurn extracts the code blocks of the turn collected so far.
+func (w *CodeWriter) endTurn() {
+	if w.text.Len() == 0 {
+		return
+	}
+
+	dir := fmt.Sprintf("turn-%03d", w.turn)
+	used := make(map[string]bool)
+	for i, block := range ParseCodeBlocks(w.text.String()) {
+		name := block.Filename
+		if name == "" {
+			name = "block-" + strconv.Itoa(i+1) + codeExtension(block.Language)
+		}
+		name = uniqueName(used, name)
+
+		w.blocks = append(w.blocks, codeFileBlock{
+			file: CodeFile{
+				Path:     path.Join(dir, name),
+				Turn:     w.turn,
+				Block:    i + 1,
+				Language: block.Language,
+				Filename: block.Filename,
+				Lines:    strings.Count(block.Code, "\n"),
+			},
+			code: block.Code,
+		})
+	}
+	w.text.Reset()
+}
+
+// uniqueName returns name, or name with a number added before its extension
+// if it is used already, and marks the result as used.
+func uniqueName(used map[string]bool, name string) string {
+	unique := name
+	ext := path.Ext(name)
+	for n := 2; used[unique]; n++ {
+		unique = strings.TrimSuffix(name, ext) + "-" + strconv.Itoa(n) + ext
+	}
+	used[unique] = true
+	return unique
+}
+
+// End writes the collected code blocks and the index.
+func (w *CodeWriter) End() error {
+	w.endTurn()
+	if err := os.MkdirAll(w.OutputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	index := codeIndex{Source: w.source, Files: []CodeFile{}}
+	for _, block := range w.blocks {
+		target := filepath.Join(w.OutputDir, filepath.FromSlash(block.file.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("error creating output directory: %w", err)
+		}
+		if err := writeOutput(nil, target, w.Clobber, []byte(block.code)); err != nil {
+			return err
+		}
+		index.Files = append(index.Files, block.file)
+	}
+	w.Files = index.Files
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding code index: %w", err)
+	}
+	return writeOutput(nil, filepath.Join(w.OutputDir, CodeIndexName), w.Clobber, append(data, '\n'))
+}
+
+// Abort drops the collected code blocks without writing them.
+func (w *CodeWriter) Abort() {
+	w.text.Reset()
+	w.blocks = nil
+}
+
+// ExtractCode writes the code blocks of the prompt read from r with w.
+// source names the input. The conversations of a ChatGPT or Claude export
+// are each written to a subdirectory of w.OutputDir named after their title
+// and ID, like the outputs of a batch, with an index of their own. w.Files
+// lists the files of all conversations, relative to w.OutputDir.
+func ExtractCode(r io.Reader, source string, w *CodeWriter) error {
+	return ExtractCodeContext(context.Background(), r, source, w)
+}
+
+// ExtractCodeContext is like ExtractCode but stops when ctx is cancelled.
+func ExtractCodeContext(ctx context.Context, r io.Reader, source string, w *CodeWriter) error {
+	format, r, err := DetectFormat(r)
+	if err != nil {
+		return err
+	}
+	if format == AIStudioInput {
+		return ExportStreamContext(ctx, r, source, w)
+	}
+
+	var files []CodeFile
+	used := make(map[string]bool)
+	err = readConversations(r, format, source, func(conv Conversation) error {
+		dir := uniqueName(used, conversationName(conv))
+		cw := &CodeWriter{OutputDir: filepath.Join(w.OutputDir, dir), Clobber: w.Clobber}
+		if err := cw.WriteContext(ctx, conv.Root); err != nil {
+			return err
+		}
+		for _, file := range cw.Files {
+			file.Path = path.Join(dir, file.Path)
+			files = append(files, file)
+		}
+		return nil
+	})
+	w.Files = files
+	return err
+}
+
+var (
+	// filenameComment matches a first line of code that only names a file,
+	// e.g. "// main.go" or "# filename: app.py".
+	filenameComment = regexp.MustCompile(`^\s*(?://|#|--|;|/\*|<!--)\s*(?:(?i:file(?:name)?):\s*)?([\w./-]+\.\w+)\s*(?:\*/|-->)?\s*$`)
+	// filenameLine matches a line before a fence that only names a file,
+	// e.g. "**main.go**" or "### `src/app.ts`:".
+	filenameLine = regexp.MustCompile("^[#*_\\s]*`?([\\w./-]+\\.\\w+)`?[*_:\\s]*$")
+	// filenameAttribute matches a file name given in an info string, e.g.
+	// `title="main.go"`.
+	filenameAttribute = regexp.MustCompile(`^(?:title|file|filename|name)=["']?([^"']+)["']?$`)
+)
+
+// ParseCodeBlocks returns the fenced code blocks of Markdown text. A block
+// that is not closed extends to the end of text.
+func ParseCodeBlocks(text string) []CodeBlock {
+	var blocks []CodeBlock
+	lines := strings.SplitAfter(text, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		indent, fence, info, ok := openingFence(lines[i])
+		if !ok {
+			continue
+		}
+
+		var code strings.Builder
+		j := i + 1
+		for ; j < len(lines); j++ {
+			if closesFence(lines[j], fence) {
+				break
+			}
+			code.WriteString(trimIndent(lines[j], indent))
+		}
+
+		block := CodeBlock{Code: code.String()}
+		if block.Code != "" && !strings.HasSuffix(block.Code, "\n") {
+			block.Code += "\n"
+		}
+		block.Language, block.Filename = parseInfo(info)
+		if block.Filename == "" {
+			first, _, _ := strings.Cut(block.Code, "\n")
+			if m := filenameComment.FindStringSubmatch(first); m != nil {
+				block.Filename = m[1]
+			}
+		}
+		if block.Filename == "" && i > 0 {
+			if m := filenameLine.FindStringSubmatch(strings.TrimSpace(lines[i-1])); m != nil {
+				block.Filename = m[1]
+			}
+		}
+		block.Filename = safeFilename(block.Filename)
+		if block.Language == "" && block.Filename != "" {
+			block.Language = codeLanguage(path.Ext(block.Filename))
+		}
+
+		blocks = append(blocks, block)
+		i = j
+	}
+	return blocks
+}
+
+// openingFence parses a line that opens a fenced code block, returning its
+// indentation, its fence and its info string.
+func openingFence(line string) (indent int, fence, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	indent = len(line) - len(trimmed)
+	if indent > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return 0, "", "", false
+	}
+
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 {
+		return 0, "", "", false
+	}
+	fence, info = trimmed[:n], strings.TrimSpace(trimmed[n:])
+	if fence[0] == '`' && strings.Contains(info, "`") {
+		return 0, "", "", false
+	}
+	return indent, fence, info, true
+}
+
+// closesFence reports whether line closes a block opened with fence.
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 || len(trimmed) < len(fence) {
+		return false
+	}
+	return strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// trimIndent removes up to indent leading spaces from line.
+func trimIndent(line string, indent int) string {
+	for i := 0; i < indent && strings.HasPrefix(line, " "); i++ {
+		line = line[1:]
+	}
+	return line
+}
+
+// parseInfo returns the language and the file name given in an info string
+// such as "go", "go main.go", "go title=main.go" or "main.go".
+func parseInfo(info string) (language, filename string) {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	language = strings.ToLower(strings.Trim(fields[0], "{}."))
+	if strings.Contains(language, ".") {
+		// A file name in place of the language.
+		filename, language = fields[0], codeLanguage(path.Ext(fields[0]))
+	}
+	for _, field := range fields[1:] {
+		if filename != "" {
+			break
+		}
+		if m := filenameAttribute.FindStringSubmatch(field); m != nil {
+			filename = m[1]
+		} else if strings.Contains(field, ".") && !strings.Contains(field, "=") {
+			filename = field
+		}
+	}
+	return language, filename
+}
+
+// safeFilename returns name as a clean relative path inside the output
+// directory, or only its base name if it would point elsewhere.
+func safeFilename(name string) string {
+	if name == "" {
+		return ""
+	}
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		name = path.Base(name)
+		if name == "/" || name == "." || name == ".." {
+			return ""
+		}
+	}
+	return name
+}
+
+// codeExtensions maps language tags to file extensions.
+var codeExtensions = map[string]string{
+	"bash":       ".sh",
+	"c":          ".c",
+	"c++":        ".cpp",
+	"cpp":        ".cpp",
+	"csharp":     ".cs",
+	"cs":         ".cs",
+	"css":        ".css",
+	"dart":       ".dart",
+	"dockerfile": ".dockerfile",
+	"go":         ".go",
+	"golang":     ".go",
+	"graphql":    ".graphql",
+	"h":          ".h",
+	"html":       ".html",
+	"java":       ".java",
+	"javascript": ".js",
+	"js":         ".js",
+	"json":       ".json",
+	"jsx":        ".jsx",
+	"kotlin":     ".kt",
+	"kt":         ".kt",
+	"lua":        ".lua",
+	"makefile":   ".mk",
+	"markdown":   ".md",
+	"md":         ".md",
+	"php":        ".php",
+	"powershell": ".ps1",
+	"proto":      ".proto",
+	"py":         ".py",
+	"python":     ".py",
+	"python3":    ".py",
+	"r":          ".r",
+	"rb":         ".rb",
+	"ruby":       ".rb",
+	"rust":       ".rs",
+	"rs":         ".rs",
+	"scala":      ".scala",
+	"sh":         ".sh",
+	"shell":      ".sh",
+	"sql":        ".sql",
+	"swift":      ".swift",
+	"toml":       ".toml",
+	"ts":         ".ts",
+	"tsx":        ".tsx",
+	"typescript": ".ts",
+	"xml":        ".xml",
+	"yaml":       ".yaml",
+	"yml":        ".yaml",
+	"zsh":        ".sh",
+}
+
+// codeExtension returns the file extension for a language tag, ".txt" for
+// unknown ones.
+func codeExtension(language string) string {
+	if ext, ok := codeExtensions[language]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// codeLanguages maps file extensions to the language tag written to the
+// index for blocks without one.
+var codeLanguages = map[string]string{
+	".c":     "c",
+	".cpp":   "cpp",
+	".cs":    "csharp",
+	".css":   "css",
+	".dart":  "dart",
+	".go":    "go",
+	".h":     "c",
+	".html":  "html",
+	".java":  "java",
+	".js":    "javascript",
+	".json":  "json",
+	".jsx":   "jsx",
+	".kt":    "kotlin",
+	".lua":   "lua",
+	".md":    "markdown",
+	".php":   "php",
+	".proto": "proto",
+	".py":    "python",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".sh":    "bash",
+	".sql":   "sql",
+	".swift": "swift",
+	".toml":  "toml",
+	".ts":    "typescript",
+	".tsx":   "tsx",
+	".xml":   "xml",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+}
+
+// codeLanguage returns the language tag for a file extension, or "" if it
+// is unknown.
+func codeLanguage(ext string) string {
+	return codeLanguages[strings.ToLower(ext)]
+}
@@ -0,0 +1,214 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []CodeBlock
+	}{
+		{
+			name:     "language tag",
+			text:     "Here:\n\n```go\npackage main\n```\n",
+			expected: []CodeBlock{{Language: "go", Code: "package main\n"}},
+		},
+		{
+			name:     "file name in info string",
+			text:     "```python app.py\nprint(1)\n```",
+			expected: []CodeBlock{{Language: "python", Filename: "app.py", Code: "print(1)\n"}},
+		},
+		{
+			name:     "title attribute",
+			text:     "```ts title=\"src/app.ts\"\nlet x = 1\n```",
+			expected: []CodeBlock{{Language: "ts", Filename: "src/app.ts", Code: "let x = 1\n"}},
+		},
+		{
+			name:     "file name as language",
+			text:     "```main.go\npackage main\n```",
+			expected: []CodeBlock{{Language: "go", Filename: "main.go", Code: "package main\n"}},
+		},
+		{
+			name:     "comment on first line",
+			text:     "```go\n// cmd/main.go\npackage main\n```",
+			expected: []CodeBlock{{Language: "go", Filename: "cmd/main.go", Code: "// cmd/main.go\npackage main\n"}},
+		},
+		{
+			name:     "line before fence",
+			text:     "**`schema.sql`:**\n```\nCREATE TABLE t (id int);\n```",
+			expected: []CodeBlock{{Language: "sql", Filename: "schema.sql", Code: "CREATE TABLE t (id int);\n"}},
+		},
+		{
+			name:     "escaping file name",
+			text:     "```sh ../../etc/profile.sh\necho\n```",
+			expected: []CodeBlock{{Language: "sh", Filename: "profile.sh", Code: "echo\n"}},
+		},
+		{
+			name:     "shebang is not a file name",
+			text:     "```\n#!/bin/sh\n```",
+			expected: []CodeBlock{{Code: "#!/bin/sh\n"}},
+		},
+		{
+			name: "nested fence and tildes",
+			text: "````md\n```go\nx\n```\n````\n~~~\ny\n~~~",
+			expected: []CodeBlock{
+				{Language: "md", Code: "```go\nx\n```\n"},
+				{Code: "y\n"},
+			},
+		},
+		{
+			name:     "indented fence",
+			text:     "1. Step\n   ```js\n   a()\n     b()\n   ```",
+			expected: []CodeBlock{{Language: "js", Code: "a()\n  b()\n"}},
+		},
+		{
+			name:     "unclosed block",
+			text:     "```rust\nfn main() {",
+			expected: []CodeBlock{{Language: "rust", Code: "fn main() {\n"}},
+		},
+		{
+			name: "no blocks",
+			text: "Use `go build` to build.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := ParseCodeBlocks(tt.text)
+			if !reflect.DeepEqual(blocks, tt.expected) {
+				t.Errorf("ParseCodeBlocks() = %+v, want %+v", blocks, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCodeWriter(t *testing.T) {
+	root := Root{
+		Source: "prompt.json",
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{
+				{Text: "Write a server\n```go\nignored\n```", Role: RoleUser},
+				{Text: "```go\nsecret()\n```", Role: RoleModel, IsThought: true},
+				{Text: "```go main.go\npackage main\n", Role: RoleModel},
+				{Text: "func main() {}\n```\nAnd a script:\n```python\nprint(1)\n```\n```\nplain\n```", Role: RoleModel},
+				{Text: "Thanks", Role: RoleUser},
+				{Text: "```go\n// main.go\npackage main // v2\n```", Role: RoleModel},
+			},
+		},
+	}
+
+	dir := filepath.Join(t.TempDir(), "code")
+	writer := &CodeWriter{OutputDir: dir}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("CodeWriter.Write failed: %v", err)
+	}
+
+	files := map[string]string{
+		"turn-002/main.go":     "package main\nfunc main() {}\n",
+		"turn-002/block-2.py":  "print(1)\n",
+		"turn-002/block-3.txt": "plain\n",
+		"turn-004/main.go":     "// main.go\npackage main // v2\n",
+	}
+	for path, expected := range files {
+		content, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Errorf("Expected %s: %v", path, err)
+			continue
+		}
+		if string(content) != expected {
+			t.Errorf("%s = %q, want %q", path, content, expected)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, CodeIndexName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index codeIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("Invalid index: %v", err)
+	}
+	expected := []CodeFile{
+		{Path: "turn-002/main.go", Turn: 2, Block: 1, Language: "go", Filename: "main.go", Lines: 2},
+		{Path: "turn-002/block-2.py", Turn: 2, Block: 2, Language: "python", Lines: 1},
+		{Path: "turn-002/block-3.txt", Turn: 2, Block: 3, Lines: 1},
+		{Path: "turn-004/main.go", Turn: 4, Block: 1, Language: "go", Filename: "main.go", Lines: 2},
+	}
+	if index.Source != "prompt.json" || !reflect.DeepEqual(index.Files, expected) {
+		t.Errorf("Index = %+v, want files %+v", index, expected)
+	}
+	if !reflect.DeepEqual(writer.Files, expected) {
+		t.Errorf("Files = %+v, want %+v", writer.Files, expected)
+	}
+}
+
+func TestCodeWriter_DuplicateNames(t *testing.T) {
+	root := Root{ChunkedPrompt: ChunkedPrompt{Chunks: []Chunk{
+		{Text: "```go main.go\nv1\n```\n```go main.go\nv2\n```", Role: RoleModel},
+	}}}
+
+	dir := t.TempDir()
+	writer := &CodeWriter{OutputDir: dir}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("CodeWriter.Write failed: %v", err)
+	}
+
+	for path, expected := range map[string]string{"turn-001/main.go": "v1\n", "turn-001/main-2.go": "v2\n"} {
+		content, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil || string(content) != expected {
+			t.Errorf("%s = %q (%v), want %q", path, content, err, expected)
+		}
+	}
+}
+
+func TestExtractCode_Conversations(t *testing.T) {
+	input := `[
+		{"uuid": "11111111-0000", "name": "First", "chat_messages": [
+			{"sender": "human", "text": "Go?"},
+			{"sender": "assistant", "text": "` + "```go main.go\\nv1\\n```" + `"}
+		]},
+		{"uuid": "22222222-0000", "name": "Second", "chat_messages": [
+			{"sender": "human", "text": "Again?"},
+			{"sender": "assistant", "text": "` + "```go main.go\\nv2\\n```" + `"}
+		]}
+	]`
+
+	dir := t.TempDir()
+	writer := &CodeWriter{OutputDir: dir}
+	if err := ExtractCode(strings.NewReader(input), "conversations.json", writer); err != nil {
+		t.Fatalf("ExtractCode failed: %v", err)
+	}
+
+	for path, expected := range map[string]string{
+		"first-11111111/turn-002/main.go":  "v1\n",
+		"second-22222222/turn-002/main.go": "v2\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil || string(content) != expected {
+			t.Errorf("%s = %q (%v), want %q", path, content, err, expected)
+		}
+	}
+	for _, sub := range []string{"first-11111111", "second-22222222"} {
+		data, err := os.ReadFile(filepath.Join(dir, sub, CodeIndexName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var index codeIndex
+		if err := json.Unmarshal(data, &index); err != nil || len(index.Files) != 1 || index.Files[0].Path != "turn-002/main.go" {
+			t.Errorf("Index of %s = %+v (%v)", sub, index, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, CodeIndexName)); err == nil {
+		t.Error("Expected no index at the top of the output directory")
+	}
+	if len(writer.Files) != 2 || writer.Files[1].Path != "second-22222222/turn-002/main.go" {
+		t.Errorf("Files = %+v", writer.Files)
+	}
+}
@@ -0,0 +1,147 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cancelReader reads from r and cancels its context once r is exhausted,
+// as if the export was interrupted while waiting for more input.
+type cancelReader struct {
+	r      io.Reader
+	rest   io.Reader
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF {
+		c.cancel()
+		return c.rest.Read(p)
+	}
+	return n, err
+}
+
+// interruptedInput returns a reader that yields the first chunks of a prompt
+// and cancels ctx before the rest of the document.
+func interruptedInput(cancel context.CancelFunc) io.Reader {
+	return &cancelReader{
+		r:      strings.NewReader(`{"chunkedPrompt": {"chunks": [{"text": "One"}, {"text": "Two"},`),
+		rest:   strings.NewReader(` {"text": "Three"}]}}`),
+		cancel: cancel,
+	}
+}
+
+func TestExportStreamContext_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	recorder := &streamRecorder{}
+	err := ExportStreamContext(ctx, interruptedInput(cancel), "in", recorder)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	expected := "begin:in:,chunk:One,chunk:Two,abort"
+	if got := strings.Join(recorder.calls, ","); got != expected {
+		t.Errorf("Calls = %q, want %q", got, expected)
+	}
+}
+
+func TestExportChunksContext_AlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+	err := ExportChunksContext(ctx, "../../example.json", &TextWriter{OutputPath: outputPath})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file, got %v", err)
+	}
+}
+
+func TestExportReaderContext_DocumentWriter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	outputPath := filepath.Join(t.TempDir(), "output.md")
+	err := ExportReaderContext(ctx, interruptedInput(cancel), "in", &MarkdownWriter{OutputPath: outputPath})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file, got %v", err)
+	}
+}
+
+func TestSQLiteWriter_CancelRollsBack(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// A completed export must survive the cancelled one.
+	if err := ExportReader(strings.NewReader(`{"chunkedPrompt": {"chunks": [{"text": "Kept"}]}}`), "kept", &SQLiteWriter{DBPath: dbPath}); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := ExportReaderContext(ctx, interruptedInput(cancel), "cancelled", &SQLiteWriter{DBPath: dbPath})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db)
+
+	var conversations []ConversationRecord
+	if err := db.Preload("Messages").Find(&conversations).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(conversations) != 1 || conversations[0].SourcePath != "kept" || len(conversations[0].Messages) != 1 {
+		t.Errorf("Expected only the completed conversation, got %+v", conversations)
+	}
+}
+
+func TestExportBatchContext_Cancel(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.json": `{"chunkedPrompt": {"chunks": [{"text": "A"}]}}`,
+		"b.json": `{"chunkedPrompt": {"chunks": [{"text": "B"}]}}`,
+		"c.json": `{"chunkedPrompt": {"chunks": [{"text": "C"}]}}`,
+	})
+	inputs, err := ExpandInputs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var created int
+	result := ExportBatchContext(ctx, inputs, func(input Input) (Writer, error) {
+		created++
+		if created == 2 {
+			cancel()
+		}
+		return &TextWriter{OutputPath: input.OutputPath(dir, ".txt")}, nil
+	})
+
+	if len(result.Succeeded) != 1 || len(result.Failed) != 1 {
+		t.Fatalf("Expected 1 success and 1 failure, got %+v", result)
+	}
+	if !errors.Is(result.Failed[0].Err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", result.Failed[0].Err)
+	}
+	if created != 2 {
+		t.Errorf("Expected the batch to stop after cancellation, created %d writers", created)
+	}
+}
@@ -0,0 +1,308 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Price is the price of a model's tokens, per million tokens.
+type Price struct {
+	Input  float64 `json:"input" yaml:"input"`
+	Output float64 `json:"output" yaml:"output"`
+	// Thinking is the price of thought tokens; 0 means the Output price.
+	Thinking float64 `json:"thinking,omitempty" yaml:"thinking,omitempty"`
+}
+
+// PriceTable maps model names to their prices.
+type PriceTable struct {
+	// Currency is the unit of the prices, e.g. "USD".
+	Currency string           `json:"currency" yaml:"currency"`
+	Models   map[string]Price `json:"models" yaml:"models"`
+}
+
+// DefaultPriceTable returns the list prices of common Gemini models, in USD
+// for prompts of up to 200k tokens, as published when this table was last
+// updated. Load a price table with LoadPriceTable for other models or
+// current prices.
+func DefaultPriceTable() PriceTable {
+	return PriceTable{
+		Currency: "USD",
+		Models: map[string]Price{
+			"gemini-2.5-pro":        {Input: 1.25, Output: 10},
+			"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+			"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+			"gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+			"gemini-2.0-flash-lite": {Input: 0.075, Output: 0.30},
+		},
+	}
+}
+
+// LoadPriceTable reads a price table from a YAML file (.yaml or .yml) or a
+// JSON file (any other extension). All prices are in the currency of the
+// table; unknown fields, such as a currency of a model's own, are rejected.
+// For example:
+//
+//	currency: USD
+//	models:
+//	  gemini-2.5-pro: {input: 1.25, output: 10}
+//	  gemini-2.5-flash: {input: 0.30, output: 2.50, thinking: 2.50}
+func LoadPriceTable(path string) (PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PriceTable{}, fmt.Errorf("error reading price table: %w", err)
+	}
+
+	var table PriceTable
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&table)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&table)
+	}
+	if err != nil {
+		return PriceTable{}, fmt.Errorf("error parsing price table %s: %w", path, err)
+	}
+	if len(table.Models) == 0 {
+		return PriceTable{}, fmt.Errorf("error parsing price table %s: no models", path)
+	}
+	if table.Currency == "" {
+		table.Currency = "USD"
+	}
+	return table, nil
+}
+
+// Price returns the price of model. The "models/" prefix of AI Studio model
+// names is ignored, and a model without a price of its own takes that of the
+// longest model name it starts with, so "gemini-2.5-pro-preview-06-05" is
+// priced as "gemini-2.5-pro".
+func (t PriceTable) Price(model string) (Price, bool) {
+	model = strings.TrimPrefix(model, "models/")
+	if price, ok := t.Models[model]; ok {
+		return price, true
+	}
+
+	var best string
+	for name := range t.Models {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t.Models[best], true
+}
+
+// EstimateTokens estimates the number of tokens of text at about four
+// characters per token, for chunks without a tokenCount.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// Cost is the estimated cost of running a conversation turn by turn: every
+// model turn is a request whose input is the system instruction and every
+// answer and user chunk before it, and whose output is the model's answer
+// and thoughts. Thoughts are not sent back as input.
+type Cost struct {
+	Source         string `json:"source,omitempty"`
+	Model          string `json:"model,omitempty"`
+	InputTokens    int    `json:"inputTokens"`
+	OutputTokens   int    `json:"outputTokens"`
+	ThinkingTokens int    `json:"thinkingTokens"`
+	// EstimatedTokens counts the tokens of the conversation that were
+	// estimated by EstimateTokens rather than taken from tokenCount.
+	EstimatedTokens int `json:"estimatedTokens"`
+	// Priced reports whether the price table has a price for Model; the
+	// amounts are zero otherwise.
+	Priced   bool    `json:"priced"`
+	Currency string  `json:"currency,omitempty"`
+	Input    float64 `json:"input"`
+	Output   float64 `json:"output"`
+	Thinking float64 `json:"thinking"`
+	Total    float64 `json:"total"`
+
+	// mixed reports that costs in different currencies were added.
+	mixed bool
+}
+
+// Add adds the tokens and amounts of other to c, which is priced if any of
+// them is. Amounts in different currencies are not added up: once c has
+// received both, it is no longer priced.
+func (c *Cost) Add(other Cost) {
+	c.InputTokens += other.InputTokens
+	c.OutputTokens += other.OutputTokens
+	c.ThinkingTokens += other.ThinkingTokens
+	c.EstimatedTokens += other.EstimatedTokens
+	if !other.Priced && !other.mixed {
+		return
+	}
+	if other.mixed || (c.Priced && c.Currency != other.Currency) {
+		c.mixed = true
+	}
+	if c.mixed {
+		c.Priced, c.Currency = false, ""
+		c.Input, c.Output, c.Thinking, c.Total = 0, 0, 0, 0
+		return
+	}
+	c.Priced = true
+	c.Currency = other.Currency
+	c.Input += other.Input
+	c.Output += other.Output
+	c.Thinking += other.Thinking
+	c.Total += other.Total
+}
+
+// Mixed reports whether costs in different currencies were added to c,
+// which leaves it unpriced.
+func (c Cost) Mixed() bool {
+	return c.mixed
+}
+
+// costCounter counts the tokens of a conversation chunk by chunk.
+type costCounter struct {
+	cost Cost
+	// context is the number of tokens sent as input with the next request.
+	context int
+	role    string
+	// system is the estimated size of the system instruction, which is
+	// sent with each of the requests counted so far.
+	system   int
+	requests int
+}
+
+func newCostCounter(meta Root) costCounter {
+	c := costCounter{}
+	c.updateMeta(meta)
+	return c
+}
+
+// updateMeta takes the model and system instruction from meta, correcting
+// the tokens counted so far for a system instruction that was not known yet.
+func (c *costCounter) updateMeta(meta Root) {
+	c.cost.Source, c.cost.Model = meta.Source, meta.RunSettings.Model
+	tokens := EstimateTokens(meta.SystemInstruction.Content())
+	delta := tokens - c.system
+	c.system = tokens
+	c.context += delta
+	c.cost.EstimatedTokens += delta
+	c.cost.InputTokens += delta * c.requests
+}
+
+func (c *costCounter) add(chunk Chunk) {
+	if chunk.Role == RoleModel && c.role != RoleModel {
+		// A new model turn sends everything before it.
+		c.cost.InputTokens += c.context
+		c.requests++
+	}
+	c.role = chunk.Role
+
+	// The token count of a chunk whose parts mix thoughts and answers
+	// covers all of them: estimate the pieces before the last one and give
+	// the rest to the last.
+	pieces := splitThoughts(chunk)
+	remaining := chunk.TokenCount
+	for i, piece := range pieces {
+		var tokens int
+		switch {
+		case chunk.TokenCount == 0:
+			tokens = EstimateTokens(piece.Content())
+			c.cost.EstimatedTokens += tokens
+		case i == len(pieces)-1:
+			tokens = remaining
+		default:
+			tokens = min(EstimateTokens(piece.Content()), remaining)
+			remaining -= tokens
+			c.cost.EstimatedTokens += tokens
+		}
+
+		switch {
+		case piece.IsThought:
+			c.cost.ThinkingTokens += tokens
+		case chunk.Role == RoleModel:
+			c.cost.OutputTokens += tokens
+			c.context += tokens
+		default:
+			c.context += tokens
+		}
+	}
+}
+
+// result prices the tokens counted with prices.
+func (c *costCounter) result(prices PriceTable) Cost {
+	cost := c.cost
+	price, ok := prices.Price(cost.Model)
+	if !ok {
+		return cost
+	}
+	thinking := price.Thinking
+	if thinking == 0 {
+		thinking = price.Output
+	}
+	cost.Priced = true
+	cost.Currency = prices.Currency
+	cost.Input = float64(cost.InputTokens) * price.Input / 1e6
+	cost.Output = float64(cost.OutputTokens) * price.Output / 1e6
+	cost.Thinking = float64(cost.ThinkingTokens) * thinking / 1e6
+	cost.Total = cost.Input + cost.Output + cost.Thinking
+	return cost
+}
+
+// CostWriter estimates the cost of every conversation exported to it.
+type CostWriter struct {
+	Prices PriceTable
+	// Costs receives the cost of each conversation once it ends.
+	Costs []Cost
+
+	counter costCounter
+}
+
+// Write estimates the cost of root.
+func (w *CostWriter) Write(root Root) error {
+	return w.WriteContext(context.Background(), root)
+}
+
+// WriteContext is like Write but stops when ctx is cancelled.
+func (w *CostWriter) WriteContext(ctx context.Context, root Root) error {
+	return writeDocument(ctx, w, root)
+}
+
+// Begin starts counting the tokens of a conversation.
+func (w *CostWriter) Begin(meta Root) error {
+	w.counter = newCostCounter(meta)
+	return nil
+}
+
+// WriteChunk counts the tokens of chunk.
+func (w *CostWriter) WriteChunk(chunk Chunk) error {
+	w.counter.add(chunk)
+	return nil
+}
+
+// updateMeta takes the model and system instruction that follow the chunks.
+func (w *CostWriter) updateMeta(meta Root) error {
+	w.counter.updateMeta(meta)
+	return nil
+}
+
+// End records the cost of the conversation.
+func (w *CostWriter) End() error {
+	w.Costs = append(w.Costs, w.counter.result(w.Prices))
+	return nil
+}
+
+// Abort drops the tokens counted for the conversation.
+func (w *CostWriter) Abort() {
+	w.counter = costCounter{}
+}
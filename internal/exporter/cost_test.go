@@ -0,0 +1,210 @@
+package exporter
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPriceTable_Price(t *testing.T) {
+	table := PriceTable{Models: map[string]Price{
+		"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+		"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+	}}
+
+	tests := []struct {
+		model    string
+		expected Price
+		ok       bool
+	}{
+		{"gemini-2.5-flash", Price{Input: 0.30, Output: 2.50}, true},
+		{"models/gemini-2.5-flash", Price{Input: 0.30, Output: 2.50}, true},
+		{"models/gemini-2.5-flash-preview-05-20", Price{Input: 0.30, Output: 2.50}, true},
+		{"models/gemini-2.5-flash-lite-preview", Price{Input: 0.10, Output: 0.40}, true},
+		{"models/gemini-2.5-pro", Price{}, false},
+		{"", Price{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			price, ok := table.Price(tt.model)
+			if price != tt.expected || ok != tt.ok {
+				t.Errorf("Price(%q) = %+v, %v, want %+v, %v", tt.model, price, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"prices.yaml": "currency: EUR\nmodels:\n  gemini-2.5-pro: {input: 1.25, output: 10, thinking: 12}\n",
+		"prices.json": `{"models": {"gemini-2.5-pro": {"input": 1.25, "output": 10}}}`,
+		"empty.yml":   "currency: USD\n",
+		"broken.json": `{"models": [`,
+		"mixed.yaml":  "currency: USD\nmodels:\n  gemini-2.5-pro: {input: 1.25, output: 10, currency: EUR}\n",
+		"mixed.json":  `{"currency": "USD", "models": {"gemini-2.5-pro": {"input": 1.25, "output": 10, "currency": "EUR"}}}`,
+	})
+
+	expected := PriceTable{Currency: "EUR", Models: map[string]Price{"gemini-2.5-pro": {Input: 1.25, Output: 10, Thinking: 12}}}
+	table, err := LoadPriceTable(filepath.Join(dir, "prices.yaml"))
+	if err != nil {
+		t.Fatalf("LoadPriceTable failed: %v", err)
+	}
+	if !reflect.DeepEqual(table, expected) {
+		t.Errorf("LoadPriceTable() = %+v, want %+v", table, expected)
+	}
+
+	expected = PriceTable{Currency: "USD", Models: map[string]Price{"gemini-2.5-pro": {Input: 1.25, Output: 10}}}
+	table, err = LoadPriceTable(filepath.Join(dir, "prices.json"))
+	if err != nil {
+		t.Fatalf("LoadPriceTable failed: %v", err)
+	}
+	if !reflect.DeepEqual(table, expected) {
+		t.Errorf("LoadPriceTable() = %+v, want %+v", table, expected)
+	}
+
+	for _, name := range []string{"empty.yml", "broken.json", "missing.yaml", "mixed.yaml", "mixed.json"} {
+		if _, err := LoadPriceTable(filepath.Join(dir, name)); err == nil {
+			t.Errorf("Expected error loading %s, got nil", name)
+		}
+	}
+}
+
+func TestCost_AddCurrencies(t *testing.T) {
+	usd := Cost{InputTokens: 1, Priced: true, Currency: "USD", Total: 1}
+	eur := Cost{InputTokens: 2, Priced: true, Currency: "EUR", Total: 2}
+	unpriced := Cost{InputTokens: 4}
+
+	var total Cost
+	total.Add(usd)
+	total.Add(unpriced)
+	total.Add(usd)
+	if !total.Priced || total.Currency != "USD" || total.Total != 2 || total.InputTokens != 6 || total.Mixed() {
+		t.Errorf("Unexpected total: %+v", total)
+	}
+
+	total.Add(eur)
+	total.Add(usd)
+	if total.Priced || total.Currency != "" || total.Total != 0 || total.InputTokens != 9 || !total.Mixed() {
+		t.Errorf("Unexpected total of mixed currencies: %+v", total)
+	}
+
+	var sum Cost
+	sum.Add(total)
+	if sum.Priced || !sum.Mixed() {
+		t.Errorf("Expected a sum of a mixed total to stay mixed: %+v", sum)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := map[string]int{"": 0, "Hi": 1, "Hello world!": 3, "Привет": 2}
+	for text, expected := range tests {
+		if got := EstimateTokens(text); got != expected {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, expected)
+		}
+	}
+}
+
+func TestCostWriter(t *testing.T) {
+	// One currency unit per input token and two per output token.
+	prices := PriceTable{Currency: "USD", Models: map[string]Price{"gemini-2.5-pro": {Input: 1e6, Output: 2e6}}}
+	writer := &CostWriter{Prices: prices}
+	if err := writer.Write(statsRoot()); err != nil {
+		t.Fatalf("CostWriter.Write failed: %v", err)
+	}
+
+	root := statsRoot()
+	root.Source = "unpriced.json"
+	root.RunSettings.Model = "models/other"
+	root.SystemInstruction = SystemInstruction{Text: "Be brief"}
+	root.ChunkedPrompt.Chunks = []Chunk{
+		{Text: "Hi", Role: RoleUser},
+		{Text: "Hello there", Role: RoleModel},
+	}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("CostWriter.Write failed: %v", err)
+	}
+
+	expected := []Cost{
+		{
+			// The first model turn reads the user chunk (3), the second
+			// also the first answer (7) and question (3); the thought
+			// part of the mixed chunk is estimated.
+			Source: "prompt.json", Model: "models/gemini-2.5-pro",
+			InputTokens: 16, OutputTokens: 11, ThinkingTokens: 3, EstimatedTokens: 1,
+			Priced: true, Currency: "USD", Input: 16, Output: 22, Thinking: 6, Total: 44,
+		},
+		{
+			Source: "unpriced.json", Model: "models/other",
+			InputTokens: 3, OutputTokens: 3, EstimatedTokens: 6,
+		},
+	}
+	if !reflect.DeepEqual(writer.Costs, expected) {
+		t.Errorf("Costs = %+v, want %+v", writer.Costs, expected)
+	}
+}
+
+func TestCostWriter_MetadataAfterChunks(t *testing.T) {
+	prices := PriceTable{Currency: "USD", Models: map[string]Price{"x": {Input: 1e6, Output: 2e6}}}
+	writer := &CostWriter{Prices: prices}
+	if err := ExportReader(strings.NewReader(lateMetadataInput), "late.json", writer); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+
+	// The system instruction (1) is sent with the question (1).
+	expected := []Cost{{
+		Source: "late.json", Model: "models/x",
+		InputTokens: 2, OutputTokens: 2, EstimatedTokens: 4,
+		Priced: true, Currency: "USD", Input: 2, Output: 4, Total: 6,
+	}}
+	if !reflect.DeepEqual(writer.Costs, expected) {
+		t.Errorf("Costs = %+v, want %+v", writer.Costs, expected)
+	}
+}
+
+func TestSQLiteWriter_Cost(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	prices := PriceTable{Currency: "USD", Models: map[string]Price{"gemini-2.5-pro": {Input: 1e6, Output: 2e6}}}
+
+	writer := &SQLiteWriter{DBPath: dbPath, Prices: &prices}
+	if err := writer.Write(statsRoot()); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+	unpriced := statsRoot()
+	unpriced.Source = "other.json"
+	unpriced.RunSettings.Model = "models/other"
+	unpriced.ChunkedPrompt.Chunks = []Chunk{{Text: "Hi", Role: RoleUser}, {Text: "Hello", Role: RoleModel}}
+	if err := writer.Write(unpriced); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db)
+	var conversations []ConversationRecord
+	if err := db.Order("id").Find(&conversations).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(conversations) != 2 || conversations[0].Cost == nil || *conversations[0].Cost != 44 || conversations[1].Cost != nil {
+		t.Errorf("Unexpected conversations: %+v", conversations)
+	}
+}
+
+func TestStatsWriter_Cost(t *testing.T) {
+	prices := DefaultPriceTable()
+	writer := &StatsWriter{Prices: &prices}
+	if err := writer.Write(statsRoot()); err != nil {
+		t.Fatal(err)
+	}
+	cost := writer.Stats[0].Cost
+	if cost == nil || !cost.Priced || cost.Currency != "USD" || cost.InputTokens != 16 {
+		t.Fatalf("Unexpected cost: %+v", cost)
+	}
+	if summary := Summarize(writer.Stats); summary.Cost == nil || summary.Cost.Total != cost.Total {
+		t.Errorf("Unexpected summary cost: %+v", summary.Cost)
+	}
+}
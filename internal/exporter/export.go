@@ -1,8 +1,9 @@
 package exporter
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
@@ -12,41 +13,290 @@ type Writer interface {
 	Write(root Root) error
 }
 
+// StreamWriter defines the interface for exporting chunks one at a time.
+// Begin receives the document without its chunks; fields that appear after
+// the chunk list in the input are not available to it. Each chunk is then
+// passed to WriteChunk, and End finishes the export.
+type StreamWriter interface {
+	Begin(meta Root) error
+	WriteChunk(chunk Chunk) error
+	End() error
+}
+
+// ContextWriter is implemented by writers that can be cancelled while
+// writing.
+type ContextWriter interface {
+	Writer
+	WriteContext(ctx context.Context, root Root) error
+}
+
+// ContextStreamWriter is implemented by stream writers whose work can be
+// cancelled through the context passed to BeginContext, which is used in
+// place of Begin.
+type ContextStreamWriter interface {
+	StreamWriter
+	BeginContext(ctx context.Context, meta Root) error
+}
+
+// aborter is implemented by stream writers that must release resources or
+// discard partial output when an export fails before End.
+type aborter interface {
+	Abort()
+}
+
 // ExportChunks reads input JSON file and saves chunks using the provided writer.
+// Writers that implement StreamWriter receive the chunks as they are decoded.
 func ExportChunks(inputPath string, writer Writer) error {
-	root, err := readAndParse(inputPath)
+	return ExportChunksContext(context.Background(), inputPath, writer)
+}
+
+// ExportChunksContext is like ExportChunks but stops when ctx is cancelled,
+// discarding partial output, and returns the context's error.
+func ExportChunksContext(ctx context.Context, inputPath string, writer Writer) error {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %w", err)
+	}
+	defer f.Close()
+
+	return ExportReaderContext(ctx, f, inputPath, writer)
+}
+
+// ExportReader reads JSON from r and saves chunks using the provided writer.
+// source names the input, e.g. "-" for standard input.
+func ExportReader(r io.Reader, source string, writer Writer) error {
+	return ExportReaderContext(context.Background(), r, source, writer)
+}
+
+// ExportReaderContext is like ExportReader but stops when ctx is cancelled.
+//
+// ChatGPT and Claude exports are detected and passed to writer one
+// conversation at a time, each with its own Begin and End. This suits
+// writers that add to a database; use ExportBatch to write each conversation
+// to a file of its own.
+func ExportReaderContext(ctx context.Context, r io.Reader, source string, writer Writer) error {
+	format, r, err := DetectFormat(r)
 	if err != nil {
 		return err
 	}
+	sw := streamWriter(writer)
+	if format == AIStudioInput {
+		return ExportStreamContext(ctx, r, source, sw)
+	}
+	return readConversations(r, format, source, func(conv Conversation) error {
+		return writeDocument(ctx, sw, conv.Root)
+	})
+}
 
-	return writer.Write(root)
+// ExportStream decodes a prompt from r and passes it to writer chunk by
+// chunk. source names the input and is recorded as the Source of the
+// metadata given to Begin.
+func ExportStream(r io.Reader, source string, writer StreamWriter) error {
+	return ExportStreamContext(context.Background(), r, source, writer)
 }
 
-func readAndParse(inputPath string) (Root, error) {
-	data, err := os.ReadFile(inputPath)
+// ExportStreamContext is like ExportStream but stops when ctx is cancelled.
+// The context is checked between chunks and passed to writers that
+// implement ContextStreamWriter; a cancelled export is aborted.
+func ExportStreamContext(ctx context.Context, r io.Reader, source string, writer StreamWriter) (err error) {
+	began := false
+	defer func() {
+		if err != nil && began {
+			if a, ok := writer.(aborter); ok {
+				a.Abort()
+			}
+		}
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	begin := func(meta Root) error {
+		meta.Source = source
+		began = true
+		return beginContext(ctx, writer, meta)
+	}
+	writeChunk := func(chunk Chunk) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return writer.WriteChunk(chunk)
+	}
+
+	root, err := streamChunks(r, begin, writeChunk)
 	if err != nil {
-		return Root{}, fmt.Errorf("error reading input file: %w", err)
+		return err
+	}
+	if !began {
+		if err := begin(root); err != nil {
+			return err
+		}
+	} else if u, ok := writer.(metaUpdater); ok {
+		// Fields after the chunk list were not decoded yet at Begin.
+		root.Source = source
+		if err := u.updateMeta(root); err != nil {
+			return err
+		}
 	}
 
-	var root Root
-	if err := json.Unmarshal(data, &root); err != nil {
-		return Root{}, fmt.Errorf("error parsing JSON: %w", err)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return writer.End()
+}
+
+// beginContext calls BeginContext if w supports it and Begin otherwise.
+func beginContext(ctx context.Context, w StreamWriter, meta Root) error {
+	if cw, ok := w.(ContextStreamWriter); ok {
+		return cw.BeginContext(ctx, meta)
 	}
+	return w.Begin(meta)
+}
+
+// metaUpdater is implemented by stream writers that use the metadata of a
+// document after Begin, so they can take the complete metadata once the
+// whole document has been decoded, right before End.
+type metaUpdater interface {
+	updateMeta(meta Root) error
+}
+
+// AdaptWriter returns a StreamWriter that collects the chunks and passes the
+// whole document to w on End, for writers that need all chunks at once.
+func AdaptWriter(w Writer) StreamWriter {
+	return &documentWriter{writer: w}
+}
+
+type documentWriter struct {
+	writer Writer
+	ctx    context.Context
+	root   Root
+}
 
-	return root, nil
+func (d *documentWriter) Begin(meta Root) error {
+	return d.BeginContext(context.Background(), meta)
+}
+
+func (d *documentWriter) BeginContext(ctx context.Context, meta Root) error {
+	d.ctx = ctx
+	d.root = meta
+	d.root.ChunkedPrompt.Chunks = nil
+	return nil
+}
+
+func (d *documentWriter) WriteChunk(chunk Chunk) error {
+	d.root.ChunkedPrompt.Chunks = append(d.root.ChunkedPrompt.Chunks, chunk)
+	return nil
+}
+
+// updateMeta replaces the metadata given to Begin, keeping the chunks.
+func (d *documentWriter) updateMeta(meta Root) error {
+	chunks := d.root.ChunkedPrompt.Chunks
+	d.root = meta
+	d.root.ChunkedPrompt.Chunks = chunks
+	return nil
+}
+
+func (d *documentWriter) End() error {
+	if cw, ok := d.writer.(ContextWriter); ok {
+		return cw.WriteContext(d.ctx, d.root)
+	}
+	return d.writer.Write(d.root)
+}
+
+// streamWriter returns w itself if it supports streaming, or an adapter.
+func streamWriter(w Writer) StreamWriter {
+	if sw, ok := w.(StreamWriter); ok {
+		return sw
+	}
+	return AdaptWriter(w)
+}
+
+// writeDocument passes an already decoded document to a stream writer, so
+// stream writers can implement Writer on top of their streaming methods.
+func writeDocument(ctx context.Context, w StreamWriter, root Root) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := beginContext(ctx, w, root); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if a, ok := w.(aborter); ok {
+				a.Abort()
+			}
+		}
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	for _, chunk := range root.ChunkedPrompt.Chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := w.WriteChunk(chunk); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.End()
 }
 
 // ProcessChunks filters chunks and joins their text.
 func ProcessChunks(root Root) string {
 	var texts []string
+	for _, chunk := range filterChunks(root, ExcludeThoughts) {
+		texts = append(texts, chunk.Content())
+	}
+
+	return strings.Join(texts, "\n---\n")
+}
+
+// filterChunks returns the chunks of root that carry text and belong to the
+// main output in mode, after splitting chunks that mix thought and answer
+// parts.
+func filterChunks(root Root, mode ThoughtMode) []Chunk {
+	return filterChunksFunc(root, func(chunk Chunk) bool {
+		return mode.keeps(chunk.IsThought) && chunk.Content() != ""
+	})
+}
+
+// filterChunksFunc returns the chunks of root, split like in filterChunks,
+// for which keep returns true.
+func filterChunksFunc(root Root, keep func(Chunk) bool) []Chunk {
+	var chunks []Chunk
 	for _, chunk := range root.ChunkedPrompt.Chunks {
-		if chunk.IsThought {
-			continue
-		}
-		if chunk.Text != "" {
-			texts = append(texts, chunk.Text)
+		for _, piece := range splitThoughts(chunk) {
+			if keep(piece) {
+				chunks = append(chunks, piece)
+			}
 		}
 	}
+	return chunks
+}
 
-	return strings.Join(texts, "\n---\n")
+// turn is a run of consecutive chunks from the same speaker.
+type turn struct {
+	Role   string
+	Chunks []Chunk
+}
+
+// groupTurns groups consecutive chunks with the same role into turns.
+func groupTurns(chunks []Chunk) []turn {
+	var turns []turn
+	for _, chunk := range chunks {
+		if n := len(turns); n > 0 && turns[n-1].Role == chunk.Role {
+			turns[n-1].Chunks = append(turns[n-1].Chunks, chunk)
+			continue
+		}
+		turns = append(turns, turn{Role: chunk.Role, Chunks: []Chunk{chunk}})
+	}
+	return turns
 }
@@ -1,15 +1,39 @@
 package exporter
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// readAndParse decodes the prompt file at inputPath; see parse.
+func readAndParse(inputPath string) (Root, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return Root{}, err
+	}
+	defer f.Close()
+
+	return parse(f, inputPath)
+}
+
+// parse decodes a prompt from r the way exports do, recording source as its
+// origin.
+func parse(r io.Reader, source string) (Root, error) {
+	recorder := &recordingWriter{}
+	if err := ExportStream(r, source, AdaptWriter(recorder)); err != nil {
+		return Root{}, err
+	}
+	return recorder.roots[0], nil
+}
+
 func TestProcessChunks(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -384,3 +408,375 @@ func TestSQLiteWriter_LargeDataset(t *testing.T) {
 		t.Errorf("Expected 500 records, got %d", count)
 	}
 }
+
+func TestReadAndParse_Example(t *testing.T) {
+	root, err := readAndParse(filepath.Join("..", "..", "example.json"))
+	if err != nil {
+		t.Fatalf("readAndParse failed: %v", err)
+	}
+
+	chunks := root.ChunkedPrompt.Chunks
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+
+	if chunks[0].Role != RoleUser || chunks[0].TokenCount != 2 {
+		t.Errorf("Chunk 0: got role %q, tokenCount %d", chunks[0].Role, chunks[0].TokenCount)
+	}
+	if !chunks[1].IsThought || len(chunks[1].Parts) != 2 || !chunks[1].Parts[0].Thought {
+		t.Errorf("Chunk 1: expected thought chunk with 2 thought parts, got %+v", chunks[1])
+	}
+
+	answer := chunks[2]
+	if answer.Role != RoleModel || answer.FinishReason != "STOP" || answer.TokenCount != 10 {
+		t.Errorf("Chunk 2: got role %q, finishReason %q, tokenCount %d", answer.Role, answer.FinishReason, answer.TokenCount)
+	}
+	if len(answer.Parts) != 2 || answer.Parts[1].ThoughtSignature == "" {
+		t.Errorf("Chunk 2: expected thoughtSignature on second part, got %+v", answer.Parts)
+	}
+}
+
+func TestChunkContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunk    Chunk
+		expected string
+	}{
+		{"Text only", Chunk{Text: "Hello"}, "Hello"},
+		{"Text wins over parts", Chunk{Text: "Hello", Parts: []Part{{Text: "Other"}}}, "Hello"},
+		{"Parts fallback", Chunk{Parts: []Part{{Text: "Hel"}, {Text: "lo"}}}, "Hello"},
+		{"Empty", Chunk{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.chunk.Content(); got != tt.expected {
+				t.Errorf("Content() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSQLiteWriter_ChunkFields(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	root := Root{
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{
+				{Text: "Question", Role: RoleUser, TokenCount: 3},
+				{Role: RoleModel, TokenCount: 5, FinishReason: "STOP", Parts: []Part{{Text: "Answer"}}},
+			},
+		},
+	}
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	var records []ChunkRecord
+	if err := db.Order("id").Find(&records).Error; err != nil {
+		t.Fatalf("Failed to query records: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Role != RoleUser || records[0].TokenCount != 3 {
+		t.Errorf("Record 0: got %+v", records[0])
+	}
+	if records[1].Text != "Answer" || records[1].FinishReason != "STOP" || records[1].TokenCount != 5 {
+		t.Errorf("Record 1: got %+v", records[1])
+	}
+}
+
+func TestReadAndParse_ExampleMetadata(t *testing.T) {
+	root, err := readAndParse(filepath.Join("..", "..", "example.json"))
+	if err != nil {
+		t.Fatalf("readAndParse failed: %v", err)
+	}
+
+	s := root.RunSettings
+	if s.Model != "models/gemini-3-flash-preview" || s.Temperature != 1 || s.TopP != 0.95 || s.TopK != 64 {
+		t.Errorf("Unexpected run settings: %+v", s)
+	}
+	if s.MaxOutputTokens != 65536 || s.ThinkingLevel != "THINKING_HIGH" || len(s.SafetySettings) != 4 {
+		t.Errorf("Unexpected run settings: %+v", s)
+	}
+	if root.SystemInstruction.Content() != "" {
+		t.Errorf("Expected empty system instruction, got %q", root.SystemInstruction.Content())
+	}
+}
+
+func TestFormatMetadata(t *testing.T) {
+	root := Root{
+		RunSettings: RunSettings{
+			Model:               "models/gemini-pro",
+			Temperature:         0.5,
+			TopK:                40,
+			SafetySettings:      []SafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "OFF"}},
+			EnableCodeExecution: true,
+		},
+		SystemInstruction: SystemInstruction{Parts: []Part{{Text: "Be brief."}}},
+	}
+
+	expected := "model: models/gemini-pro\n" +
+		"temperature: 0.5\n" +
+		"topK: 40\n" +
+		"safetySettings: HARM_CATEGORY_HARASSMENT=OFF\n" +
+		"tools: codeExecution\n" +
+		"systemInstruction: Be brief.\n"
+	if got := FormatMetadata(root); got != expected {
+		t.Errorf("FormatMetadata() = %q, want %q", got, expected)
+	}
+
+	if got := FormatMetadata(Root{}); got != "" {
+		t.Errorf("FormatMetadata() of empty root = %q, want empty", got)
+	}
+}
+
+func TestTextWriter_Metadata(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+
+	root := Root{
+		RunSettings: RunSettings{Model: "models/gemini-pro"},
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{{Text: "Line 1"}, {Text: "Line 2"}},
+		},
+	}
+
+	writer := &TextWriter{OutputPath: outputPath, Metadata: true}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("TextWriter.Write failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "model: models/gemini-pro\n===\nLine 1\n---\nLine 2"
+	if string(result) != expected {
+		t.Errorf("Result = %q, want %q", string(result), expected)
+	}
+}
+
+func TestTextWriter_MetadataStreamed(t *testing.T) {
+	var buf bytes.Buffer
+	writer := &TextWriter{Output: &buf, Metadata: true}
+	if err := writer.Begin(Root{RunSettings: RunSettings{Model: "models/x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteChunk(Chunk{Text: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	// Metadata known at Begin is written without holding the text back.
+	if writer.body != nil {
+		t.Error("Expected the text not to be held in memory")
+	}
+	if err := writer.End(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "model: models/x\n===\nHi"; buf.String() != expected {
+		t.Errorf("Output = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestTextWriter_MetadataAroundChunks(t *testing.T) {
+	input := `{
+		"runSettings": {"model": "models/x"},
+		"chunkedPrompt": {"chunks": [{"text": "Hi"}, {"text": "Hello!"}]},
+		"systemInstruction": {"text": "SYS"}
+	}`
+
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+	if err := ExportReader(strings.NewReader(input), "-", &TextWriter{OutputPath: outputPath, Metadata: true}); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "model: models/x\nsystemInstruction: SYS\n===\nHi\n---\nHello!"; string(result) != expected {
+		t.Errorf("Result = %q, want %q", result, expected)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(outputPath)); len(entries) != 1 {
+		t.Errorf("Expected temporary files to be removed, got %d entries", len(entries))
+	}
+
+	// The header written to a stream cannot be taken back.
+	var buf bytes.Buffer
+	if err := ExportReader(strings.NewReader(input), "-", &TextWriter{Output: &buf, Metadata: true}); err == nil {
+		t.Error("Expected an error for metadata split around the chunks, got nil")
+	}
+}
+
+func TestTextWriter_Roles(t *testing.T) {
+	root := Root{
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{
+				{Text: "Hi", Role: RoleUser},
+				{Text: "Thinking", Role: RoleModel, IsThought: true},
+				{Text: "Hello!\nHow can I help?", Role: RoleModel},
+				{Text: "Calling tool", Role: "tool"},
+				{Text: "No role"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		writer   TextWriter
+		expected string
+	}{
+		{
+			name:     "Default labels",
+			writer:   TextWriter{Roles: true},
+			expected: "User: Hi\n---\nModel: Hello!\nHow can I help?\n---\nTool: Calling tool\n---\nNo role",
+		},
+		{
+			name:     "Custom labels",
+			writer:   TextWriter{Roles: true, UserLabel: "Q:", ModelLabel: "A:"},
+			expected: "Q: Hi\n---\nA: Hello!\nHow can I help?\n---\nTool: Calling tool\n---\nNo role",
+		},
+		{
+			name:     "Labels ignored without roles",
+			writer:   TextWriter{UserLabel: "Q:", ModelLabel: "A:"},
+			expected: "Hi\n---\nHello!\nHow can I help?\n---\nCalling tool\n---\nNo role",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := tt.writer
+			writer.OutputPath = filepath.Join(t.TempDir(), "output.txt")
+			if err := writer.Write(root); err != nil {
+				t.Fatalf("TextWriter.Write failed: %v", err)
+			}
+
+			result, err := os.ReadFile(writer.OutputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(result) != tt.expected {
+				t.Errorf("Result = %q, want %q", string(result), tt.expected)
+			}
+		})
+	}
+}
+
+func TestExportReader_Output(t *testing.T) {
+	input := `{"chunkedPrompt": {"chunks": [
+		{"text": "Hi", "role": "user"},
+		{"text": "Hello!", "role": "model"}
+	]}}`
+
+	tests := []struct {
+		name     string
+		writer   func(out io.Writer) Writer
+		expected string
+	}{
+		{
+			name:     "Text",
+			writer:   func(out io.Writer) Writer { return &TextWriter{Output: out} },
+			expected: "Hi\n---\nHello!",
+		},
+		{
+			name:     "Markdown",
+			writer:   func(out io.Writer) Writer { return &MarkdownWriter{Output: out} },
+			expected: "## User\n\nHi\n\n## Model\n\nHello!\n",
+		},
+		{
+			name:     "OpenAI JSONL",
+			writer:   func(out io.Writer) Writer { return &OpenAIJSONLWriter{Output: out} },
+			expected: `{"messages":[{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello!"}]}` + "\n",
+		},
+		{
+			name:     "Gemini JSONL",
+			writer:   func(out io.Writer) Writer { return &GeminiJSONLWriter{Output: out} },
+			expected: `{"contents":[{"role":"user","parts":[{"text":"Hi"}]},{"role":"model","parts":[{"text":"Hello!"}]}]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ExportReader(strings.NewReader(input), "-", tt.writer(&buf)); err != nil {
+				t.Fatalf("ExportReader failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Output = %q, want %q", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+// lateMetadataInput is a prompt whose run settings and system instruction
+// follow the chunks.
+const lateMetadataInput = `{
+	"chunkedPrompt": {"chunks": [{"text": "Hi", "role": "user"}, {"text": "Hello!", "role": "model"}]},
+	"runSettings": {"model": "models/x"},
+	"systemInstruction": {"text": "SYS"}
+}`
+
+func TestExportReader_MetadataAfterChunks(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		writer   func(out io.Writer) Writer
+		expected string
+	}{
+		{
+			name:     "Markdown",
+			writer:   func(out io.Writer) Writer { return &MarkdownWriter{Output: out} },
+			expected: "model: models/x",
+		},
+		{
+			name:     "OpenAI JSONL",
+			writer:   func(out io.Writer) Writer { return &OpenAIJSONLWriter{Output: out} },
+			expected: `{"role":"system","content":"SYS"}`,
+		},
+		{
+			name:     "Text",
+			writer:   func(out io.Writer) Writer { return &TextWriter{Output: out, Metadata: true} },
+			expected: "model: models/x\nsystemInstruction: SYS\n===\nHi\n---\n",
+		},
+		{
+			name: "Filtered Gemini JSONL",
+			writer: func(out io.Writer) Writer {
+				return &FilterWriter{Writer: &GeminiJSONLWriter{Output: out}, Filter: RoleFilter(RoleUser, RoleModel)}
+			},
+			expected: `"systemInstruction":{"role":"system","parts":[{"text":"SYS"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ExportReader(strings.NewReader(lateMetadataInput), "-", tt.writer(&buf)); err != nil {
+				t.Fatalf("ExportReader failed: %v", err)
+			}
+			if !strings.Contains(buf.String(), tt.expected) || !strings.Contains(buf.String(), "Hello!") {
+				t.Errorf("Output = %q, want it to contain %q and the chunks", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTMLWriter_Output(t *testing.T) {
+	var buf bytes.Buffer
+	root := Root{ChunkedPrompt: ChunkedPrompt{Chunks: []Chunk{{Text: "Hello", Role: RoleModel}}}}
+	if err := (&HTMLWriter{Output: &buf}).Write(root); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "<p>Hello</p>") {
+		t.Errorf("Expected rendered chunk in output, got %q", buf.String())
+	}
+}
@@ -0,0 +1,185 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Position locates a chunk in its conversation.
+type Position struct {
+	// Index is the 0-based index of the chunk in the input.
+	Index int
+	// Turn is the 1-based number of the turn the chunk belongs to. A turn
+	// is a run of consecutive chunks with the same role, so a model's
+	// thoughts and its answer form one turn.
+	Turn int
+}
+
+// Filter reports whether the chunk at pos is exported.
+type Filter func(chunk Chunk, pos Position) bool
+
+// AllFilters returns a filter that keeps the chunks kept by every filter.
+func AllFilters(filters ...Filter) Filter {
+	return func(chunk Chunk, pos Position) bool {
+		for _, filter := range filters {
+			if !filter(chunk, pos) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RoleFilter keeps the chunks with one of roles.
+func RoleFilter(roles ...string) Filter {
+	return func(chunk Chunk, _ Position) bool {
+		return slices.ContainsFunc(roles, func(role string) bool {
+			return strings.EqualFold(role, chunk.Role)
+		})
+	}
+}
+
+// TurnFilter keeps the chunks of turns from to to, inclusive. A bound of 0
+// leaves that end of the range open.
+func TurnFilter(from, to int) Filter {
+	return func(_ Chunk, pos Position) bool {
+		return inRange(pos.Turn, from, to)
+	}
+}
+
+// TokenFilter keeps the chunks whose token count is between min and max,
+// inclusive. A bound of 0 leaves that end of the range open.
+func TokenFilter(min, max int) Filter {
+	return func(chunk Chunk, _ Position) bool {
+		return inRange(chunk.TokenCount, min, max)
+	}
+}
+
+// FinishReasonFilter keeps the chunks with one of reasons as finish reason.
+func FinishReasonFilter(reasons ...string) Filter {
+	return func(chunk Chunk, _ Position) bool {
+		return slices.ContainsFunc(reasons, func(reason string) bool {
+			return strings.EqualFold(reason, chunk.FinishReason)
+		})
+	}
+}
+
+// TextFilter keeps the chunks whose text matches re.
+func TextFilter(re *regexp.Regexp) Filter {
+	return func(chunk Chunk, _ Position) bool {
+		return re.MatchString(chunk.Content())
+	}
+}
+
+func inRange(n, from, to int) bool {
+	return (from == 0 || n >= from) && (to == 0 || n <= to)
+}
+
+// ParseTurnRange parses a turn range such as "10-25", "10-" (from turn 10),
+// "-25" (up to turn 25) or "7" (turn 7 only) into bounds for TurnFilter.
+func ParseTurnRange(s string) (from, to int, err error) {
+	fromText, toText, isRange := strings.Cut(s, "-")
+	if !isRange {
+		toText = fromText
+	}
+	if from, err = parseTurn(fromText); err == nil {
+		to, err = parseTurn(toText)
+	}
+	if err != nil || (from == 0 && to == 0) || (to != 0 && to < from) {
+		return 0, 0, fmt.Errorf("invalid turn range: %q", s)
+	}
+	return from, to, nil
+}
+
+func parseTurn(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid turn: %q", s)
+	}
+	return n, nil
+}
+
+// FilterWriter passes the chunks selected by Filter on to Writer, as a stage
+// between parsing and writing that works with every writer.
+type FilterWriter struct {
+	Writer Writer
+	// Filter selects the chunks to write; nil keeps all of them.
+	Filter Filter
+
+	next StreamWriter
+	pos  Position
+	role string
+}
+
+// Write writes the chunks of root selected by Filter.
+func (w *FilterWriter) Write(root Root) error {
+	return w.WriteContext(context.Background(), root)
+}
+
+// WriteContext is like Write but stops when ctx is cancelled.
+func (w *FilterWriter) WriteContext(ctx context.Context, root Root) error {
+	return writeDocument(ctx, w, root)
+}
+
+// Begin starts an export with Writer.
+func (w *FilterWriter) Begin(meta Root) error {
+	return w.BeginContext(context.Background(), meta)
+}
+
+// BeginContext is like Begin but passes ctx on to Writer.
+func (w *FilterWriter) BeginContext(ctx context.Context, meta Root) error {
+	w.next = streamWriter(w.Writer)
+	w.pos = Position{Index: -1}
+	w.role = ""
+	return beginContext(ctx, w.next, meta)
+}
+
+// WriteChunk passes chunk on to Writer if Filter selects it.
+func (w *FilterWriter) WriteChunk(chunk Chunk) error {
+	w.pos.Index++
+	if w.pos.Turn == 0 || chunk.Role != w.role {
+		w.pos.Turn++
+		w.role = chunk.Role
+	}
+
+	if w.Filter != nil && !w.Filter(chunk, w.pos) {
+		return nil
+	}
+	return w.next.WriteChunk(chunk)
+}
+
+// End finishes the export with Writer.
+func (w *FilterWriter) End() error {
+	return w.next.End()
+}
+
+// updateMeta passes the complete metadata on to Writer.
+func (w *FilterWriter) updateMeta(meta Root) error {
+	if u, ok := w.next.(metaUpdater); ok {
+		return u.updateMeta(meta)
+	}
+	return nil
+}
+
+// Close closes Writer if it is an io.Closer.
+func (w *FilterWriter) Close() error {
+	if c, ok := w.Writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Abort aborts the export with Writer.
+func (w *FilterWriter) Abort() {
+	if a, ok := w.next.(aborter); ok {
+		a.Abort()
+	}
+}
@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// filterInput has three turns: a user question, a model answer preceded by
+// a thought, and a truncated second answer.
+const filterInput = `{"chunkedPrompt": {"chunks": [
+	{"text": "Question", "role": "user", "tokenCount": 2},
+	{"text": "Thinking", "role": "model", "isThought": true, "tokenCount": 40},
+	{"text": "Short answer", "role": "model", "tokenCount": 5, "finishReason": "STOP"},
+	{"text": "Follow-up", "role": "user", "tokenCount": 3},
+	{"text": "Long answer", "role": "model", "tokenCount": 500, "finishReason": "MAX_TOKENS"}
+]}}`
+
+func TestFilterWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		thoughts ThoughtMode
+		expected string
+	}{
+		{
+			name:     "No filter",
+			expected: "Question\n---\nShort answer\n---\nFollow-up\n---\nLong answer",
+		},
+		{
+			name:     "Role",
+			filter:   RoleFilter("MODEL"),
+			expected: "Short answer\n---\nLong answer",
+		},
+		{
+			name:     "Turn range",
+			filter:   TurnFilter(2, 3),
+			thoughts: IncludeThoughts,
+			expected: "Thinking\n---\nShort answer\n---\nFollow-up",
+		},
+		{
+			name:     "Open turn range",
+			filter:   TurnFilter(4, 0),
+			expected: "Long answer",
+		},
+		{
+			name:     "Token bounds",
+			filter:   TokenFilter(3, 100),
+			thoughts: IncludeThoughts,
+			expected: "Thinking\n---\nShort answer\n---\nFollow-up",
+		},
+		{
+			name:     "Finish reason",
+			filter:   FinishReasonFilter("max_tokens"),
+			expected: "Long answer",
+		},
+		{
+			name:     "Regex",
+			filter:   TextFilter(regexp.MustCompile(`(?i)answer$`)),
+			expected: "Short answer\n---\nLong answer",
+		},
+		{
+			name:     "Composed",
+			filter:   AllFilters(RoleFilter(RoleModel), TurnFilter(0, 2)),
+			expected: "Short answer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			writer := &FilterWriter{Writer: &TextWriter{Output: &buf, Thoughts: tt.thoughts}, Filter: tt.filter}
+			if err := ExportReader(strings.NewReader(filterInput), "in", writer); err != nil {
+				t.Fatalf("ExportReader failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Output = %q, want %q", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterWriter_DocumentWriter(t *testing.T) {
+	root, err := parse(strings.NewReader(filterInput), "in")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	writer := &FilterWriter{Writer: &MarkdownWriter{Output: &buf}, Filter: TurnFilter(3, 4)}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	expected := "## User\n\nFollow-up\n\n## Model\n\nLong answer\n"
+	if buf.String() != expected {
+		t.Errorf("Output = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestParseTurnRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		from, to int
+		wantErr  bool
+	}{
+		{input: "10-25", from: 10, to: 25},
+		{input: "10-", from: 10},
+		{input: "-25", to: 25},
+		{input: "7", from: 7, to: 7},
+		{input: "25-10", wantErr: true},
+		{input: "-", wantErr: true},
+		{input: "0-3", wantErr: true},
+		{input: "a-b", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			from, to, err := ParseTurnRange(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %d-%d", from, to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTurnRange failed: %v", err)
+			}
+			if from != tt.from || to != tt.to {
+				t.Errorf("ParseTurnRange(%q) = %d, %d, want %d, %d", tt.input, from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+func init() {
+	Register("html", nil, Factory{Extension: ".html", Assets: true, New: func(opts WriterOptions) (Writer, error) {
+		return &HTMLWriter{OutputPath: opts.OutputPath, Output: opts.Output, Clobber: opts.Clobber, Metadata: opts.Metadata, Thoughts: opts.Thoughts, Assets: opts.Assets}, nil
+	}})
+}
+
+// HTMLWriter writes chunks to a self-contained HTML page that renders the
+// conversation as chat bubbles.
+type HTMLWriter struct {
+	OutputPath string
+	// Output, if set, receives the output instead of the file at OutputPath.
+	Output io.Writer
+	// Clobber selects what happens when the file at OutputPath already
+	// exists.
+	Clobber ClobberMode
+	// Metadata adds a collapsible section with the run settings and system
+	// instruction.
+	Metadata bool
+	// Thoughts selects the thoughts to export. Alongside the conversation
+	// they are rendered in collapsible sections.
+	Thoughts ThoughtMode
+	// Assets, if set, receives the attachments of the chunks. Inline
+	// attachments are embedded in the page as data: URIs, so that it stays
+	// self-contained; Drive attachments are linked.
+	Assets *AssetStore
+}
+
+type htmlPage struct {
+	Title    string
+	Metadata []MetadataField
+	Turns    []htmlTurn
+}
+
+type htmlTurn struct {
+	Role    string
+	Speaker string
+	Blocks  []htmlBlock
+}
+
+type htmlBlock struct {
+	Thought     bool
+	Body        template.HTML
+	Attachments []htmlAttachment
+}
+
+// htmlAttachment is an attachment of a chunk, shown in the page if Embedded
+// is set and linked otherwise.
+type htmlAttachment struct {
+	Embedded bool
+	Label    string
+	URL      template.URL
+}
+
+// Write writes the chunks to an HTML file.
+func (w *HTMLWriter) Write(root Root) error {
+	if w.Thoughts == SeparateThoughts {
+		return writeSeparately(root, w.OutputPath, w.Output, func(mode ThoughtMode, path string) Writer {
+			variant := *w
+			variant.Thoughts, variant.OutputPath = mode, path
+			return &variant
+		})
+	}
+
+	content, err := w.render(root)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(w.Output, w.OutputPath, w.Clobber, content)
+}
+
+func (w *HTMLWriter) render(root Root) ([]byte, error) {
+	page := htmlPage{Title: "AI Studio conversation"}
+	if model := root.RunSettings.Model; model != "" {
+		page.Title += " (" + strings.TrimPrefix(model, "models/") + ")"
+	}
+	if w.Metadata {
+		page.Metadata = Metadata(root)
+	}
+
+	chunks := filterChunksFunc(root, func(chunk Chunk) bool {
+		return w.Thoughts.keeps(chunk.IsThought) && hasContent(chunk, w.Assets != nil)
+	})
+	for _, t := range groupTurns(chunks) {
+		ht := htmlTurn{Role: t.Role, Speaker: roleName(t.Role)}
+		for _, chunk := range t.Chunks {
+			body, err := renderMarkdown(strings.TrimRight(chunk.Content(), "\n"))
+			if err != nil {
+				return nil, err
+			}
+			attachments, err := w.attachments(root.Source, chunk)
+			if err != nil {
+				return nil, err
+			}
+			thought := chunk.IsThought && w.Thoughts != OnlyThoughts
+			ht.Blocks = append(ht.Blocks, htmlBlock{Thought: thought, Body: body, Attachments: attachments})
+		}
+		page.Turns = append(page.Turns, ht)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, page); err != nil {
+		return nil, fmt.Errorf("error rendering HTML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// attachments saves the attachments of chunk to Assets, if it is set, and
+// returns them for the page, inline ones as data: URIs.
+func (w *HTMLWriter) attachments(source string, chunk Chunk) ([]htmlAttachment, error) {
+	if w.Assets == nil {
+		return nil, nil
+	}
+	var attachments []htmlAttachment
+	for _, attachment := range chunk.Attachments() {
+		asset, data := newAsset(source, attachment)
+		if err := w.Assets.save(asset, data); err != nil {
+			return nil, err
+		}
+
+		a := htmlAttachment{Label: "File"}
+		if asset.IsImage() {
+			a.Label = "Image"
+		}
+		if asset.Path == "" {
+			a.Label = "Drive " + strings.ToLower(a.Label)
+			a.URL = template.URL(asset.URL)
+		} else {
+			a.Embedded = asset.IsImage()
+			a.URL = template.URL(dataURI(asset.MimeType, data))
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+// dataURI returns a data: URI holding data, labelled with mimeType if it is
+// a valid media type.
+func dataURI(mimeType string, data []byte) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = "application/octet-stream"
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle("github"),
+			highlighting.WithFormatOptions(chromahtml.WithClasses(false)),
+		),
+	),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(escapedHTMLRenderer{}, 100)),
+	),
+)
+
+// renderMarkdown converts text to HTML. Raw HTML in the source is escaped
+// rather than passed through, so the result is safe to embed in the page.
+func renderMarkdown(text string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(text), &buf); err != nil {
+		return "", fmt.Errorf("error rendering markdown: %w", err)
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// escapedHTMLRenderer renders raw HTML nodes as escaped text.
+type escapedHTMLRenderer struct{}
+
+func (escapedHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindRawHTML, renderRawHTML)
+	reg.Register(ast.KindHTMLBlock, renderHTMLBlock)
+}
+
+func renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	segments := node.(*ast.RawHTML).Segments
+	for i := 0; i < segments.Len(); i++ {
+		segment := segments.At(i)
+		template.HTMLEscape(w, segment.Value(source))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.HTMLBlock)
+	_, _ = w.WriteString("<p>")
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		template.HTMLEscape(w, line.Value(source))
+	}
+	if block.HasClosure() {
+		template.HTMLEscape(w, block.ClosureLine.Value(source))
+	}
+	_, _ = w.WriteString("</p>\n")
+	return ast.WalkContinue, nil
+}
+
+var htmlTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; background: #f4f5f7; color: #1f2328; font: 15px/1.55 -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; }
+main { max-width: 860px; margin: 0 auto; padding: 24px 16px; }
+h1 { font-size: 20px; margin: 0 0 16px; }
+.metadata { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 8px 12px; margin-bottom: 16px; }
+.metadata dl { display: grid; grid-template-columns: max-content 1fr; gap: 4px 12px; margin: 8px 0 0; }
+.metadata dt { font-weight: 600; }
+.metadata dd { margin: 0; white-space: pre-wrap; word-break: break-word; }
+.turn { display: flex; margin: 12px 0; }
+.turn.user { justify-content: flex-end; }
+.bubble { max-width: 85%; padding: 10px 14px; border-radius: 14px; background: #fff; border: 1px solid #d0d7de; overflow-wrap: anywhere; }
+.turn.user .bubble { background: #dbeafe; border-color: #bfdbfe; border-bottom-right-radius: 4px; }
+.turn.model .bubble { border-bottom-left-radius: 4px; }
+.speaker { font-size: 12px; font-weight: 600; color: #57606a; text-transform: uppercase; letter-spacing: .04em; }
+.bubble > :first-child { margin-top: 0; }
+.bubble p:last-child { margin-bottom: 0; }
+.thought { margin: 8px 0; padding: 6px 10px; border-left: 3px solid #d0d7de; color: #57606a; font-size: 14px; }
+.thought summary { cursor: pointer; font-weight: 600; }
+pre { padding: 10px 12px; border-radius: 6px; overflow-x: auto; font-size: 13px; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+:not(pre) > code { background: rgba(175, 184, 193, .2); padding: 1px 4px; border-radius: 4px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; }
+blockquote { margin: 0; padding-left: 12px; border-left: 3px solid #d0d7de; color: #57606a; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{- if .Metadata}}
+<details class="metadata">
+<summary>Run settings</summary>
+<dl>
+{{- range .Metadata}}
+<dt>{{.Key}}</dt><dd>{{.Value}}</dd>
+{{- end}}
+</dl>
+</details>
+{{- end}}
+{{- range .Turns}}
+<div class="turn {{.Role}}">
+<div class="bubble">
+{{- if .Speaker}}
+<div class="speaker">{{.Speaker}}</div>
+{{- end}}
+{{- range .Blocks}}
+{{- if .Thought}}
+<details class="thought">
+<summary>Thinking</summary>
+{{.Body}}
+{{- template "attachments" .Attachments}}
+</details>
+{{- else}}
+{{.Body}}
+{{- template "attachments" .Attachments}}
+{{- end}}
+{{- end}}
+</div>
+</div>
+{{- end}}
+</main>
+</body>
+</html>
+{{- define "attachments"}}
+{{- range .}}
+{{- if .Embedded}}
+<p><img src="{{.URL}}" alt="{{.Label}}"></p>
+{{- else}}
+<p><a href="{{.URL}}">{{.Label}}</a></p>
+{{- end}}
+{{- end}}
+{{- end}}
+`))
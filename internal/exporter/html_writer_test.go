@@ -0,0 +1,123 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHTMLWriter(t *testing.T) {
+	root := Root{
+		RunSettings: RunSettings{Model: "models/gemini-pro"},
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{
+				{Text: "Show me <b>code</b>", Role: RoleUser},
+				{Text: "Planning the answer", Role: RoleModel, IsThought: true},
+				{Text: "Here:\n\n```go\nfunc main() {}\n```\n\n<script>alert(1)</script>\n", Role: RoleModel},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		writer   HTMLWriter
+		contains []string
+		excludes []string
+	}{
+		{
+			name:   "Default",
+			writer: HTMLWriter{},
+			contains: []string{
+				"<title>AI Studio conversation (gemini-pro)</title>",
+				`<div class="turn user">`,
+				`<div class="turn model">`,
+				"Show me &lt;b&gt;code&lt;/b&gt;",
+				"&lt;script&gt;alert(1)&lt;/script&gt;",
+				"<pre style=",
+				"<style>",
+			},
+			excludes: []string{"<script>", "<b>code</b>", "Planning the answer", `class="metadata"`},
+		},
+		{
+			name:   "Thoughts and metadata",
+			writer: HTMLWriter{Thoughts: IncludeThoughts, Metadata: true},
+			contains: []string{
+				`<details class="thought">`,
+				"<p>Planning the answer</p>",
+				`<details class="metadata">`,
+				"<dt>model</dt><dd>models/gemini-pro</dd>",
+			},
+			excludes: []string{"<script>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := tt.writer
+			writer.OutputPath = filepath.Join(t.TempDir(), "output.html")
+			if err := writer.Write(root); err != nil {
+				t.Fatalf("HTMLWriter.Write failed: %v", err)
+			}
+
+			result, err := os.ReadFile(writer.OutputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.contains {
+				if !strings.Contains(string(result), want) {
+					t.Errorf("Output does not contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(string(result), unwanted) {
+					t.Errorf("Output unexpectedly contains %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestHTMLWriter_WriteError(t *testing.T) {
+	writer := &HTMLWriter{OutputPath: filepath.Join(t.TempDir(), "missing", "output.html")}
+	root := Root{
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{{Text: "Test"}},
+		},
+	}
+
+	if err := writer.Write(root); err == nil {
+		t.Error("Expected error when writing to invalid path, got nil")
+	}
+}
+
+func TestHTMLWriter_AssetsSelfContained(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewAssetStore(filepath.Join(dir, "assets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputPath := filepath.Join(dir, "output.html")
+	if err := (&HTMLWriter{OutputPath: outputPath, Assets: store}).Write(attachmentRoot(t)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := regexp.MustCompile(`(?:src|href)="([^"]*)"`).FindAllStringSubmatch(string(result), -1)
+	if len(links) == 0 {
+		t.Fatalf("Expected attachments in the page, got:\n%s", result)
+	}
+	for _, link := range links {
+		if !strings.HasPrefix(link[1], "data:") && !strings.HasPrefix(link[1], "https://") {
+			t.Errorf("Expected no reference to a local file, got %q", link[1])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "assets", pngAsset)); err != nil {
+		t.Errorf("Expected the image to be saved to the asset directory: %v", err)
+	}
+}
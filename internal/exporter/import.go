@@ -0,0 +1,337 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TranscriptFormat selects the shape of a transcript read by
+// ImportTranscript.
+type TranscriptFormat int
+
+const (
+	// TextTranscript is the shape written by TextWriter with Roles set:
+	// chunks separated by "---" lines, each starting with a speaker label,
+	// after an optional metadata header.
+	TextTranscript TranscriptFormat = iota
+	// MarkdownTranscript is the shape written by MarkdownWriter: a
+	// front-matter block and one "## User" or "## Model" section per turn,
+	// with thoughts in <details> blocks.
+	MarkdownTranscript
+)
+
+// DefaultModel is the model of imported prompts whose transcript does not
+// name one.
+const DefaultModel = "models/gemini-2.5-pro"
+
+// DefaultRunSettings returns the run settings of imported prompts whose
+// transcript has no metadata, matching those of a new AI Studio prompt.
+func DefaultRunSettings() RunSettings {
+	return RunSettings{
+		Model:           DefaultModel,
+		Temperature:     1,
+		TopP:            0.95,
+		TopK:            64,
+		MaxOutputTokens: 65536,
+		SafetySettings: []SafetySetting{
+			{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "OFF"},
+			{Category: "HARM_CATEGORY_HATE_SPEECH", Threshold: "OFF"},
+			{Category: "HARM_CATEGORY_SEXUALLY_EXPLICIT", Threshold: "OFF"},
+			{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "OFF"},
+		},
+	}
+}
+
+// TranscriptOptions configures ImportTranscript.
+type TranscriptOptions struct {
+	Format TranscriptFormat
+	// UserLabel and ModelLabel are the speaker labels of a text transcript;
+	// they default to DefaultUserLabel and DefaultModelLabel.
+	UserLabel  string
+	ModelLabel string
+}
+
+// ImportTranscript builds a prompt from a role-labelled transcript, as
+// written by TextWriter with Roles set or by MarkdownWriter. The run settings
+// and system instruction are read from the transcript's metadata, if any, and
+// default to DefaultRunSettings otherwise. Chunks keep their role, text and
+// thought flag; consecutive answers of a Markdown turn become one chunk.
+func ImportTranscript(r io.Reader, opts TranscriptOptions) (Root, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Root{}, fmt.Errorf("error reading transcript: %w", err)
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	if opts.Format == MarkdownTranscript {
+		return importMarkdown(text)
+	}
+	return importText(text, opts)
+}
+
+// importText parses a transcript written by TextWriter.
+func importText(text string, opts TranscriptOptions) (Root, error) {
+	root := Root{RunSettings: DefaultRunSettings()}
+	if header, body, ok := textHeader(text); ok {
+		root.RunSettings = RunSettings{}
+		if err := applyMetadata(&root, parseMetadataLines(header, false)); err != nil {
+			return Root{}, err
+		}
+		text = body
+	}
+	if strings.TrimSpace(text) == "" {
+		return root, nil
+	}
+
+	w := TextWriter{UserLabel: opts.UserLabel, ModelLabel: opts.ModelLabel}
+	labels := []struct {
+		prefix  string
+		role    string
+		thought bool
+	}{
+		{w.label(RoleModel, true), RoleModel, true},
+		{w.label(RoleUser, true), RoleUser, true},
+		{w.roleLabel(RoleUser), RoleUser, false},
+		{w.roleLabel(RoleModel), RoleModel, false},
+	}
+	// TextWriter starts each chunk with its label followed by a space.
+	labelled := func(segment string) (Chunk, bool) {
+		for _, label := range labels {
+			if rest, ok := strings.CutPrefix(segment, label.prefix+" "); ok {
+				return Chunk{Role: label.role, IsThought: label.thought, Text: rest}, true
+			}
+		}
+		return Chunk{}, false
+	}
+
+	segments := strings.Split(text, "\n---\n")
+	_, roles := labelled(segments[0])
+
+	var chunks []Chunk
+	for _, segment := range segments {
+		chunk, ok := labelled(segment)
+		switch {
+		case ok:
+			chunks = append(chunks, chunk)
+		case roles && len(chunks) > 0:
+			// A "---" line that is part of the text.
+			chunks[len(chunks)-1].Text += "\n---\n" + segment
+		default:
+			chunks = append(chunks, Chunk{Role: nextRole(chunks), Text: segment})
+		}
+	}
+	root.ChunkedPrompt.Chunks = chunks
+	return root, nil
+}
+
+// textHeader splits a text transcript into its metadata header and body,
+// if it has a header.
+func textHeader(text string) (header, body string, ok bool) {
+	if rest, ok := strings.CutPrefix(text, metadataSeparator); ok {
+		return "", rest, true
+	}
+	header, body, found := strings.Cut(text, "\n"+metadataSeparator)
+	if !found {
+		return "", "", false
+	}
+	key, _, _ := strings.Cut(header, ":")
+	if !metadataKeys[key] {
+		return "", "", false
+	}
+	return header, body, true
+}
+
+// importMarkdown parses a transcript written by MarkdownWriter. Only lines
+// framed the way the writer frames them are taken as structure: a heading or
+// thoughts block has a blank line before it and is outside code blocks, and
+// a heading has a blank line after it and names another speaker than the
+// one before, as the writer only starts a section when the speaker changes.
+// Lines that merely look like them are kept as text.
+func importMarkdown(text string) (Root, error) {
+	root := Root{RunSettings: DefaultRunSettings()}
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		key, _, _ := strings.Cut(rest, ":")
+		if header, body, found := strings.Cut(rest, "\n---\n"); found && metadataKeys[key] {
+			root.RunSettings = RunSettings{}
+			if err := applyMetadata(&root, parseMetadataLines(header, true)); err != nil {
+				return Root{}, err
+			}
+			text = body
+		}
+	}
+
+	var (
+		chunks  []Chunk
+		role    string
+		answer  []string
+		thought []string
+		inside  bool
+		// fence is the marker of the code block the current line is in.
+		fence string
+	)
+	flush := func(lines []string, isThought bool) {
+		content := strings.Trim(strings.Join(lines, "\n"), "\n")
+		if content == "" {
+			return
+		}
+		chunkRole := role
+		if chunkRole == "" {
+			chunkRole = nextRole(chunks)
+		}
+		chunks = append(chunks, Chunk{Role: chunkRole, IsThought: isThought, Text: content})
+	}
+
+	lines := strings.Split(text, "\n")
+	blank := func(i int) bool {
+		return i < 0 || i >= len(lines) || lines[i] == ""
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		heading := strings.ToLower(strings.TrimPrefix(line, "## "))
+		switch {
+		case inside:
+			if line == "</details>" {
+				flush(thought, true)
+				thought, inside = nil, false
+			} else {
+				thought = append(thought, line)
+			}
+		case fence != "":
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			answer = append(answer, line)
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+			answer = append(answer, line)
+		case line == "<details>" && blank(i-1) && i+1 < len(lines) && lines[i+1] == "<summary>Thoughts</summary>":
+			flush(answer, false)
+			answer, inside = nil, true
+			i++
+		case (line == "## User" || line == "## Model") && heading != role && blank(i-1) && i+1 < len(lines) && lines[i+1] == "":
+			flush(answer, false)
+			answer = nil
+			role = heading
+		default:
+			answer = append(answer, line)
+		}
+	}
+	if inside {
+		flush(thought, true)
+	}
+	flush(answer, false)
+
+	root.ChunkedPrompt.Chunks = chunks
+	return root, nil
+}
+
+// nextRole returns the role of a chunk that is not labelled: the other one
+// than that of the chunk before it, starting with the user.
+func nextRole(chunks []Chunk) string {
+	if len(chunks) > 0 && chunks[len(chunks)-1].Role == RoleUser {
+		return RoleModel
+	}
+	return RoleUser
+}
+
+// metadataKeys are the keys written by Metadata.
+var metadataKeys = map[string]bool{
+	"model": true, "temperature": true, "topP": true, "topK": true,
+	"maxOutputTokens": true, "thinkingLevel": true, "outputResolution": true,
+	"safetySettings": true, "tools": true, "systemInstruction": true,
+}
+
+// parseMetadataLines parses "key: value" lines as written by FormatMetadata
+// or, if quoted is set, by the Markdown front matter. A line that does not
+// start with a known key continues the value before it.
+func parseMetadataLines(header string, quoted bool) []MetadataField {
+	var fields []MetadataField
+	for _, line := range strings.Split(header, "\n") {
+		key, value, found := strings.Cut(line, ": ")
+		if found && metadataKeys[key] {
+			if quoted && strings.HasPrefix(value, `"`) {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				}
+			}
+			fields = append(fields, MetadataField{Key: key, Value: value})
+		} else if len(fields) > 0 {
+			fields[len(fields)-1].Value += "\n" + line
+		}
+	}
+	return fields
+}
+
+// applyMetadata sets the run settings and system instruction of root from
+// metadata fields, reversing Metadata.
+func applyMetadata(root *Root, fields []MetadataField) error {
+	s := &root.RunSettings
+	for _, field := range fields {
+		var err error
+		switch field.Key {
+		case "model":
+			s.Model = field.Value
+		case "temperature":
+			s.Temperature, err = strconv.ParseFloat(field.Value, 64)
+		case "topP":
+			s.TopP, err = strconv.ParseFloat(field.Value, 64)
+		case "topK":
+			s.TopK, err = strconv.Atoi(field.Value)
+		case "maxOutputTokens":
+			s.MaxOutputTokens, err = strconv.Atoi(field.Value)
+		case "thinkingLevel":
+			s.ThinkingLevel = field.Value
+		case "outputResolution":
+			s.OutputResolution = field.Value
+		case "safetySettings":
+			for _, setting := range strings.Split(field.Value, ", ") {
+				category, threshold, _ := strings.Cut(setting, "=")
+				s.SafetySettings = append(s.SafetySettings, SafetySetting{Category: category, Threshold: threshold})
+			}
+		case "tools":
+			for _, tool := range strings.Split(field.Value, ", ") {
+				switch tool {
+				case "codeExecution":
+					s.EnableCodeExecution = true
+				case "search":
+					s.EnableSearchAsATool = true
+				case "browse":
+					s.EnableBrowseAsATool = true
+				case "autoFunctionResponse":
+					s.EnableAutoFunctionResponse = true
+				}
+			}
+		case "systemInstruction":
+			root.SystemInstruction = SystemInstruction{Text: field.Value}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s in transcript metadata: %q", field.Key, field.Value)
+		}
+	}
+	return nil
+}
+
+// PromptWriter writes a prompt as AI Studio JSON.
+type PromptWriter struct {
+	OutputPath string
+	// Output, if set, receives the output instead of the file at OutputPath.
+	Output io.Writer
+	// Clobber selects what happens when the file at OutputPath already
+	// exists.
+	Clobber ClobberMode
+}
+
+// Write writes root as AI Studio prompt JSON.
+func (w *PromptWriter) Write(root Root) error {
+	if root.ChunkedPrompt.Chunks == nil {
+		root.ChunkedPrompt.Chunks = []Chunk{}
+	}
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding prompt: %w", err)
+	}
+	return writeOutput(w.Output, w.OutputPath, w.Clobber, append(data, '\n'))
+}
@@ -0,0 +1,264 @@
+package exporter
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// conversationOf returns the role, thought flag and trimmed text of each
+// chunk, which is what a transcript preserves.
+func conversationOf(root Root) []Chunk {
+	var chunks []Chunk
+	for _, chunk := range root.ChunkedPrompt.Chunks {
+		chunks = append(chunks, Chunk{
+			Role:      chunk.Role,
+			IsThought: chunk.IsThought,
+			Text:      strings.TrimRight(chunk.Content(), "\n"),
+		})
+	}
+	return chunks
+}
+
+func TestImportTranscript_RoundTrip(t *testing.T) {
+	original, err := readAndParse(filepath.Join("..", "..", "example.json"))
+	if err != nil {
+		t.Fatalf("readAndParse failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		writer func(out *bytes.Buffer) Writer
+		format TranscriptFormat
+	}{
+		{
+			name: "text",
+			writer: func(out *bytes.Buffer) Writer {
+				return &TextWriter{Output: out, Metadata: true, Roles: true, Thoughts: IncludeThoughts}
+			},
+			format: TextTranscript,
+		},
+		{
+			name: "markdown",
+			writer: func(out *bytes.Buffer) Writer {
+				return &MarkdownWriter{Output: out, Thoughts: IncludeThoughts}
+			},
+			format: MarkdownTranscript,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transcript bytes.Buffer
+			if err := tt.writer(&transcript).Write(original); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			imported, err := ImportTranscript(&transcript, TranscriptOptions{Format: tt.format})
+			if err != nil {
+				t.Fatalf("ImportTranscript failed: %v", err)
+			}
+			if !reflect.DeepEqual(imported.RunSettings, original.RunSettings) {
+				t.Errorf("RunSettings = %+v, want %+v", imported.RunSettings, original.RunSettings)
+			}
+			if got, want := conversationOf(imported), conversationOf(original); !reflect.DeepEqual(got, want) {
+				t.Errorf("Chunks = %+v, want %+v", got, want)
+			}
+
+			// The generated JSON reads back as the same prompt.
+			var prompt bytes.Buffer
+			if err := (&PromptWriter{Output: &prompt}).Write(imported); err != nil {
+				t.Fatalf("PromptWriter.Write failed: %v", err)
+			}
+			reread, err := parse(&prompt, "prompt.json")
+			if err != nil {
+				t.Fatalf("Generated prompt is invalid: %v", err)
+			}
+			if !reflect.DeepEqual(reread.RunSettings, original.RunSettings) || !reflect.DeepEqual(conversationOf(reread), conversationOf(original)) {
+				t.Errorf("Generated prompt differs from the original:\n%s", prompt.String())
+			}
+		})
+	}
+}
+
+func TestImportTranscript_RoundTripLookalikes(t *testing.T) {
+	// Lines that look like the framing of a transcript but are not framed
+	// the way the writers frame them stay part of the chunk.
+	original := Root{RunSettings: DefaultRunSettings(), ChunkedPrompt: ChunkedPrompt{Chunks: []Chunk{
+		{Role: RoleUser, Text: "Write a chat template.\n---\nUser:name"},
+		{Role: RoleModel, Text: "Here it is:\n\n## Model\n\nHello\n---\n## User\nHi\n\n```\n\n## User\n\n```"},
+		{Role: RoleUser, Text: "No.\n\n<details>\n<summary>Thoughts"},
+	}}}
+
+	tests := []struct {
+		name   string
+		writer func(out *bytes.Buffer) Writer
+		format TranscriptFormat
+	}{
+		{
+			name: "text",
+			writer: func(out *bytes.Buffer) Writer {
+				return &TextWriter{Output: out, Roles: true}
+			},
+			format: TextTranscript,
+		},
+		{
+			name: "markdown",
+			writer: func(out *bytes.Buffer) Writer {
+				return &MarkdownWriter{Output: out}
+			},
+			format: MarkdownTranscript,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exported bytes.Buffer
+			if err := tt.writer(&exported).Write(original); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			imported, err := ImportTranscript(bytes.NewReader(exported.Bytes()), TranscriptOptions{Format: tt.format})
+			if err != nil {
+				t.Fatalf("ImportTranscript failed: %v", err)
+			}
+			if got, want := conversationOf(imported), conversationOf(original); !reflect.DeepEqual(got, want) {
+				t.Errorf("Chunks = %+v, want %+v", got, want)
+			}
+
+			var reexported bytes.Buffer
+			if err := tt.writer(&reexported).Write(imported); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if reexported.String() != exported.String() {
+				t.Errorf("Re-export differs:\n%s\nwant:\n%s", reexported.String(), exported.String())
+			}
+		})
+	}
+}
+
+func TestImportTranscript_Text(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		opts       TranscriptOptions
+		expected   []Chunk
+	}{
+		{
+			name:       "labels",
+			transcript: "User: Hi\n---\nModel (thought): Hmm\n---\nModel: Hello",
+			expected: []Chunk{
+				{Role: RoleUser, Text: "Hi"},
+				{Role: RoleModel, IsThought: true, Text: "Hmm"},
+				{Role: RoleModel, Text: "Hello"},
+			},
+		},
+		{
+			name:       "separator in text",
+			transcript: "User: Split this\n---\nplease\n---\nModel: Done",
+			expected: []Chunk{
+				{Role: RoleUser, Text: "Split this\n---\nplease"},
+				{Role: RoleModel, Text: "Done"},
+			},
+		},
+		{
+			name:       "custom labels",
+			transcript: "Me: Hi\n---\nBot: Hello",
+			opts:       TranscriptOptions{UserLabel: "Me:", ModelLabel: "Bot:"},
+			expected: []Chunk{
+				{Role: RoleUser, Text: "Hi"},
+				{Role: RoleModel, Text: "Hello"},
+			},
+		},
+		{
+			name:       "no labels",
+			transcript: "Hi\n---\nHello\n---\nBye",
+			expected: []Chunk{
+				{Role: RoleUser, Text: "Hi"},
+				{Role: RoleModel, Text: "Hello"},
+				{Role: RoleUser, Text: "Bye"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ImportTranscript(strings.NewReader(tt.transcript), tt.opts)
+			if err != nil {
+				t.Fatalf("ImportTranscript failed: %v", err)
+			}
+			if !reflect.DeepEqual(root.ChunkedPrompt.Chunks, tt.expected) {
+				t.Errorf("Chunks = %+v, want %+v", root.ChunkedPrompt.Chunks, tt.expected)
+			}
+			if !reflect.DeepEqual(root.RunSettings, DefaultRunSettings()) {
+				t.Errorf("RunSettings = %+v, want the defaults", root.RunSettings)
+			}
+		})
+	}
+}
+
+func TestImportTranscript_Metadata(t *testing.T) {
+	transcript := "model: models/gemini-pro\ntemperature: 0.5\ntools: codeExecution, search\nsystemInstruction: Be brief.\nReally.\n===\nUser: Hi"
+
+	root, err := ImportTranscript(strings.NewReader(transcript), TranscriptOptions{})
+	if err != nil {
+		t.Fatalf("ImportTranscript failed: %v", err)
+	}
+	expected := RunSettings{Model: "models/gemini-pro", Temperature: 0.5, EnableCodeExecution: true, EnableSearchAsATool: true}
+	if !reflect.DeepEqual(root.RunSettings, expected) {
+		t.Errorf("RunSettings = %+v, want %+v", root.RunSettings, expected)
+	}
+	if got := root.SystemInstruction.Content(); got != "Be brief.\nReally." {
+		t.Errorf("SystemInstruction = %q, want %q", got, "Be brief.\nReally.")
+	}
+
+	_, err = ImportTranscript(strings.NewReader("topK: many\n===\nUser: Hi"), TranscriptOptions{})
+	if err == nil {
+		t.Error("Expected error for invalid metadata")
+	}
+}
+
+func TestImportTranscript_Markdown(t *testing.T) {
+	transcript := `---
+model: models/gemini-pro
+systemInstruction: "Be\nbrief."
+---
+
+## User
+
+Hi
+
+## Model
+
+<details>
+<summary>Thoughts</summary>
+
+Hmm
+
+</details>
+
+Hello
+
+## Summary
+
+Still the model's answer.
+`
+
+	root, err := ImportTranscript(strings.NewReader(transcript), TranscriptOptions{Format: MarkdownTranscript})
+	if err != nil {
+		t.Fatalf("ImportTranscript failed: %v", err)
+	}
+	expected := []Chunk{
+		{Role: RoleUser, Text: "Hi"},
+		{Role: RoleModel, IsThought: true, Text: "Hmm"},
+		{Role: RoleModel, Text: "Hello\n\n## Summary\n\nStill the model's answer."},
+	}
+	if !reflect.DeepEqual(root.ChunkedPrompt.Chunks, expected) {
+		t.Errorf("Chunks = %+v, want %+v", root.ChunkedPrompt.Chunks, expected)
+	}
+	if root.RunSettings.Model != "models/gemini-pro" || root.SystemInstruction.Content() != "Be\nbrief." {
+		t.Errorf("Unexpected metadata: %+v, %+v", root.RunSettings, root.SystemInstruction)
+	}
+}
@@ -0,0 +1,221 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	Register("openai-jsonl", nil, Factory{Extension: ".jsonl", New: func(opts WriterOptions) (Writer, error) {
+		return &OpenAIJSONLWriter{OutputPath: opts.OutputPath, Output: opts.Output, Clobber: opts.Clobber, PerTurn: opts.PerTurn, Thoughts: opts.Thoughts}, nil
+	}})
+	Register("gemini-jsonl", nil, Factory{Extension: ".jsonl", New: func(opts WriterOptions) (Writer, error) {
+		return &GeminiJSONLWriter{OutputPath: opts.OutputPath, Output: opts.Output, Clobber: opts.Clobber, PerTurn: opts.PerTurn, Thoughts: opts.Thoughts}, nil
+	}})
+}
+
+// OpenAIJSONLWriter writes chunks as OpenAI chat fine-tuning examples, one
+// {"messages": [...]} object per line.
+type OpenAIJSONLWriter struct {
+	OutputPath string
+	// Output, if set, receives the output instead of the file at OutputPath.
+	Output io.Writer
+	// Clobber selects what happens when the file at OutputPath already
+	// exists.
+	Clobber ClobberMode
+	// PerTurn emits one example per user/model turn pair instead of one
+	// example per conversation.
+	PerTurn bool
+	// Thoughts selects the thoughts to export. Included thoughts become
+	// part of the assistant message text.
+	Thoughts ThoughtMode
+}
+
+// GeminiJSONLWriter writes chunks as Gemini tuning examples, one
+// {"contents": [...]} object per line.
+type GeminiJSONLWriter struct {
+	OutputPath string
+	// Output, if set, receives the output instead of the file at OutputPath.
+	Output io.Writer
+	// Clobber selects what happens when the file at OutputPath already
+	// exists.
+	Clobber ClobberMode
+	// PerTurn emits one example per user/model turn pair instead of one
+	// example per conversation.
+	PerTurn bool
+	// Thoughts selects the thoughts to export. Included thoughts become
+	// parts marked as thoughts.
+	Thoughts ThoughtMode
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIExample struct {
+	Messages []openAIMessage `json:"messages"`
+}
+
+type geminiPart struct {
+	Text    string `json:"text"`
+	Thought bool   `json:"thought,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiExample struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+}
+
+// Write writes the chunks to a JSONL file in the OpenAI chat format.
+func (w *OpenAIJSONLWriter) Write(root Root) error {
+	if w.Thoughts == SeparateThoughts {
+		return writeSeparately(root, w.OutputPath, w.Output, func(mode ThoughtMode, path string) Writer {
+			variant := *w
+			variant.Thoughts, variant.OutputPath = mode, path
+			return &variant
+		})
+	}
+
+	system := root.SystemInstruction.Content()
+
+	var examples []any
+	for _, turns := range trainingExamples(root, w.PerTurn, w.Thoughts) {
+		var example openAIExample
+		if system != "" {
+			example.Messages = append(example.Messages, openAIMessage{Role: "system", Content: system})
+		}
+		for _, t := range turns {
+			role := "user"
+			if t.Role == RoleModel {
+				role = "assistant"
+			}
+			example.Messages = append(example.Messages, openAIMessage{Role: role, Content: turnText(t)})
+		}
+		examples = append(examples, example)
+	}
+
+	return writeJSONL(w.Output, w.OutputPath, w.Clobber, examples)
+}
+
+// Write writes the chunks to a JSONL file in the Gemini tuning format.
+func (w *GeminiJSONLWriter) Write(root Root) error {
+	if w.Thoughts == SeparateThoughts {
+		return writeSeparately(root, w.OutputPath, w.Output, func(mode ThoughtMode, path string) Writer {
+			variant := *w
+			variant.Thoughts, variant.OutputPath = mode, path
+			return &variant
+		})
+	}
+
+	var system *geminiContent
+	if text := root.SystemInstruction.Content(); text != "" {
+		system = &geminiContent{Role: "system", Parts: []geminiPart{{Text: text}}}
+	}
+
+	var examples []any
+	for _, turns := range trainingExamples(root, w.PerTurn, w.Thoughts) {
+		example := geminiExample{SystemInstruction: system}
+		for _, t := range turns {
+			example.Contents = append(example.Contents, geminiContent{
+				Role:  t.Role,
+				Parts: geminiParts(t),
+			})
+		}
+		examples = append(examples, example)
+	}
+
+	return writeJSONL(w.Output, w.OutputPath, w.Clobber, examples)
+}
+
+// trainingExamples splits the user and model turns of root into examples.
+// Thoughts are selected by mode and other roles are skipped; with only
+// thoughts, the user turns are kept as the prompts the thoughts answer.
+// Every example starts with a user turn and ends with a model turn;
+// incomplete trailing turns are dropped.
+func trainingExamples(root Root, perTurn bool, mode ThoughtMode) [][]turn {
+	chunks := filterChunks(root, mode)
+	if mode == OnlyThoughts {
+		chunks = filterChunksFunc(root, func(chunk Chunk) bool {
+			return (chunk.IsThought || chunk.Role == RoleUser) && chunk.Content() != ""
+		})
+	}
+
+	var turns []turn
+	for _, t := range groupTurns(chunks) {
+		if t.Role != RoleUser && t.Role != RoleModel {
+			continue
+		}
+		// Merge turns that became adjacent after skipping other roles.
+		if n := len(turns); n > 0 && turns[n-1].Role == t.Role {
+			turns[n-1].Chunks = append(turns[n-1].Chunks, t.Chunks...)
+			continue
+		}
+		turns = append(turns, t)
+	}
+
+	for len(turns) > 0 && turns[0].Role != RoleUser {
+		turns = turns[1:]
+	}
+	for len(turns) > 0 && turns[len(turns)-1].Role != RoleModel {
+		turns = turns[:len(turns)-1]
+	}
+	if len(turns) == 0 {
+		return nil
+	}
+
+	if !perTurn {
+		return [][]turn{turns}
+	}
+
+	var examples [][]turn
+	for i := 0; i+1 < len(turns); i += 2 {
+		examples = append(examples, turns[i:i+2])
+	}
+	return examples
+}
+
+// turnText joins the text of all chunks in t.
+func turnText(t turn) string {
+	texts := make([]string, 0, len(t.Chunks))
+	for _, chunk := range t.Chunks {
+		texts = append(texts, chunk.Content())
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// geminiParts joins the text of consecutive chunks in t into parts, one per
+// run of thoughts or answers.
+func geminiParts(t turn) []geminiPart {
+	var parts []geminiPart
+	for _, chunk := range t.Chunks {
+		if n := len(parts); n > 0 && parts[n-1].Thought == chunk.IsThought {
+			parts[n-1].Text += "\n\n" + chunk.Content()
+			continue
+		}
+		parts = append(parts, geminiPart{Text: chunk.Content(), Thought: chunk.IsThought})
+	}
+	return parts
+}
+
+// writeJSONL writes each value as a single JSON line to out or path.
+func writeJSONL(out io.Writer, path string, mode ClobberMode, values []any) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	for _, value := range values {
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("error encoding JSONL: %w", err)
+		}
+	}
+
+	return writeOutput(out, path, mode, buf.Bytes())
+}
@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func jsonlRoot() Root {
+	return Root{
+		SystemInstruction: SystemInstruction{Text: "Be brief."},
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{
+				{Text: "Stray", Role: RoleModel},
+				{Text: "Q1", Role: RoleUser},
+				{Text: "Thinking", Role: RoleModel, IsThought: true},
+				{Text: "A1", Role: RoleModel},
+				{Text: "A1 continued", Role: RoleModel},
+				{Text: "Q2", Role: RoleUser},
+				{Text: "A2", Role: RoleModel},
+				{Text: "Unanswered", Role: RoleUser},
+			},
+		},
+	}
+}
+
+func TestOpenAIJSONLWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		perTurn  bool
+		expected string
+	}{
+		{
+			name: "Whole conversation",
+			expected: `{"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Q1"},` +
+				`{"role":"assistant","content":"A1\n\nA1 continued"},{"role":"user","content":"Q2"},{"role":"assistant","content":"A2"}]}` + "\n",
+		},
+		{
+			name:    "Per turn",
+			perTurn: true,
+			expected: `{"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Q1"},{"role":"assistant","content":"A1\n\nA1 continued"}]}` + "\n" +
+				`{"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Q2"},{"role":"assistant","content":"A2"}]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "output.jsonl")
+			writer := &OpenAIJSONLWriter{OutputPath: outputPath, PerTurn: tt.perTurn}
+			if err := writer.Write(jsonlRoot()); err != nil {
+				t.Fatalf("OpenAIJSONLWriter.Write failed: %v", err)
+			}
+
+			result, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(result) != tt.expected {
+				t.Errorf("Result = %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGeminiJSONLWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		perTurn  bool
+		expected string
+	}{
+		{
+			name: "Whole conversation",
+			expected: `{"systemInstruction":{"role":"system","parts":[{"text":"Be brief."}]},"contents":[` +
+				`{"role":"user","parts":[{"text":"Q1"}]},{"role":"model","parts":[{"text":"A1\n\nA1 continued"}]},` +
+				`{"role":"user","parts":[{"text":"Q2"}]},{"role":"model","parts":[{"text":"A2"}]}]}` + "\n",
+		},
+		{
+			name:    "Per turn",
+			perTurn: true,
+			expected: `{"systemInstruction":{"role":"system","parts":[{"text":"Be brief."}]},"contents":[` +
+				`{"role":"user","parts":[{"text":"Q1"}]},{"role":"model","parts":[{"text":"A1\n\nA1 continued"}]}]}` + "\n" +
+				`{"systemInstruction":{"role":"system","parts":[{"text":"Be brief."}]},"contents":[` +
+				`{"role":"user","parts":[{"text":"Q2"}]},{"role":"model","parts":[{"text":"A2"}]}]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "output.jsonl")
+			writer := &GeminiJSONLWriter{OutputPath: outputPath, PerTurn: tt.perTurn}
+			if err := writer.Write(jsonlRoot()); err != nil {
+				t.Fatalf("GeminiJSONLWriter.Write failed: %v", err)
+			}
+
+			result, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(result) != tt.expected {
+				t.Errorf("Result = %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJSONLWriters_NoCompleteTurn(t *testing.T) {
+	root := Root{
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{{Text: "Unanswered", Role: RoleUser}},
+		},
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "output.jsonl")
+	writer := &OpenAIJSONLWriter{OutputPath: outputPath}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("OpenAIJSONLWriter.Write failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 0 {
+		t.Errorf("Expected empty output, got %q", result)
+	}
+}
@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("md", []string{"markdown"}, Factory{Extension: ".md", Assets: true, New: func(opts WriterOptions) (Writer, error) {
+		return &MarkdownWriter{OutputPath: opts.OutputPath, Output: opts.Output, Clobber: opts.Clobber, Thoughts: opts.Thoughts, Assets: opts.Assets}, nil
+	}})
+}
+
+// MarkdownWriter writes chunks to a Markdown document with one section per
+// turn and a front-matter block describing the run settings.
+type MarkdownWriter struct {
+	OutputPath string
+	// Output, if set, receives the output instead of the file at OutputPath.
+	Output io.Writer
+	// Clobber selects what happens when the file at OutputPath already
+	// exists.
+	Clobber ClobberMode
+	// Thoughts selects the thoughts to export. Alongside the conversation
+	// they are rendered in collapsible <details> blocks.
+	Thoughts ThoughtMode
+	// Assets, if set, receives the attachments of the chunks, which are
+	// linked from the document.
+	Assets *AssetStore
+}
+
+// Write writes the chunks to a Markdown file.
+func (w *MarkdownWriter) Write(root Root) error {
+	if w.Thoughts == SeparateThoughts {
+		return writeSeparately(root, w.OutputPath, w.Output, func(mode ThoughtMode, path string) Writer {
+			variant := *w
+			variant.Thoughts, variant.OutputPath = mode, path
+			return &variant
+		})
+	}
+	content, err := w.render(root)
+	if err != nil {
+		return err
+	}
+	return writeOutput(w.Output, w.OutputPath, w.Clobber, []byte(content))
+}
+
+func (w *MarkdownWriter) render(root Root) (string, error) {
+	var sb strings.Builder
+	writeFrontMatter(&sb, root)
+
+	chunks := filterChunksFunc(root, func(chunk Chunk) bool {
+		return w.Thoughts.keeps(chunk.IsThought) && hasContent(chunk, w.Assets != nil)
+	})
+	for _, t := range groupTurns(chunks) {
+		if t.Role != "" {
+			separate(&sb)
+			sb.WriteString("## " + roleName(t.Role) + "\n")
+		}
+
+		for _, chunk := range t.Chunks {
+			text, err := chunkMarkdown(w.Assets, root.Source, outputName(w.OutputPath, w.Output), chunk)
+			if err != nil {
+				return "", err
+			}
+			separate(&sb)
+			if chunk.IsThought && w.Thoughts != OnlyThoughts {
+				sb.WriteString("<details>\n<summary>Thoughts</summary>\n\n")
+				sb.WriteString(text)
+				sb.WriteString("\n\n</details>\n")
+			} else {
+				sb.WriteString(text)
+				sb.WriteString("\n")
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// writeFrontMatter writes the metadata of root as a YAML front-matter block.
+func writeFrontMatter(sb *strings.Builder, root Root) {
+	fields := Metadata(root)
+	if len(fields) == 0 {
+		return
+	}
+
+	sb.WriteString("---\n")
+	for _, field := range fields {
+		sb.WriteString(field.Key + ": " + yamlScalar(field.Value) + "\n")
+	}
+	sb.WriteString("---\n")
+}
+
+// separate starts a new Markdown block unless the document is empty.
+func separate(sb *strings.Builder) {
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+}
+
+var plainScalar = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+
+// yamlScalar quotes value unless it is safe to use as a plain YAML scalar.
+func yamlScalar(value string) string {
+	if plainScalar.MatchString(value) {
+		return value
+	}
+	return strconv.Quote(value)
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkdownWriter(t *testing.T) {
+	root := Root{
+		RunSettings: RunSettings{Model: "models/gemini-pro", Temperature: 1},
+		SystemInstruction: SystemInstruction{
+			Text: "Answer: briefly",
+		},
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{
+				{Text: "Hi", Role: RoleUser},
+				{Text: "**Planning**\n\n", Role: RoleModel, IsThought: true},
+				{Text: "Hello!\n\n```go\nfmt.Println(\"hi\")\n```\n", Role: RoleModel},
+				{Text: "More", Role: RoleModel},
+				{Text: "", Role: RoleUser},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		writer   MarkdownWriter
+		expected string
+	}{
+		{
+			name:   "Without thoughts",
+			writer: MarkdownWriter{},
+			expected: "---\n" +
+				"model: models/gemini-pro\n" +
+				"temperature: 1\n" +
+				"systemInstruction: \"Answer: briefly\"\n" +
+				"---\n" +
+				"\n## User\n\nHi\n" +
+				"\n## Model\n\nHello!\n\n```go\nfmt.Println(\"hi\")\n```\n" +
+				"\nMore\n",
+		},
+		{
+			name:   "With thoughts",
+			writer: MarkdownWriter{Thoughts: IncludeThoughts},
+			expected: "---\n" +
+				"model: models/gemini-pro\n" +
+				"temperature: 1\n" +
+				"systemInstruction: \"Answer: briefly\"\n" +
+				"---\n" +
+				"\n## User\n\nHi\n" +
+				"\n## Model\n\n<details>\n<summary>Thoughts</summary>\n\n**Planning**\n\n</details>\n" +
+				"\nHello!\n\n```go\nfmt.Println(\"hi\")\n```\n" +
+				"\nMore\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := tt.writer
+			writer.OutputPath = filepath.Join(t.TempDir(), "output.md")
+			if err := writer.Write(root); err != nil {
+				t.Fatalf("MarkdownWriter.Write failed: %v", err)
+			}
+
+			result, err := os.ReadFile(writer.OutputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(result) != tt.expected {
+				t.Errorf("Result = %q, want %q", string(result), tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdownWriter_NoMetadata(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output.md")
+	root := Root{
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{{Text: "Plain"}},
+		},
+	}
+
+	writer := &MarkdownWriter{OutputPath: outputPath}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("MarkdownWriter.Write failed: %v", err)
+	}
+
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(result) != "Plain\n" {
+		t.Errorf("Result = %q, want %q", string(result), "Plain\n")
+	}
+}
+
+func TestMarkdownWriter_WriteError(t *testing.T) {
+	writer := &MarkdownWriter{OutputPath: filepath.Join(t.TempDir(), "missing", "output.md")}
+	root := Root{
+		ChunkedPrompt: ChunkedPrompt{
+			Chunks: []Chunk{{Text: "Test"}},
+		},
+	}
+
+	if err := writer.Write(root); err == nil {
+		t.Error("Expected error when writing to invalid path, got nil")
+	}
+}
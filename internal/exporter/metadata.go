@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MetadataField is a single labelled value describing how a prompt was run.
+type MetadataField struct {
+	Key   string
+	Value string
+}
+
+// Metadata returns the run settings and system instruction of root as
+// ordered key/value pairs. Unset values are omitted.
+func Metadata(root Root) []MetadataField {
+	s := root.RunSettings
+	var fields []MetadataField
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, MetadataField{Key: key, Value: value})
+		}
+	}
+
+	add("model", s.Model)
+	if s.Temperature != 0 {
+		add("temperature", strconv.FormatFloat(s.Temperature, 'g', -1, 64))
+	}
+	if s.TopP != 0 {
+		add("topP", strconv.FormatFloat(s.TopP, 'g', -1, 64))
+	}
+	if s.TopK != 0 {
+		add("topK", strconv.Itoa(s.TopK))
+	}
+	if s.MaxOutputTokens != 0 {
+		add("maxOutputTokens", strconv.Itoa(s.MaxOutputTokens))
+	}
+	add("thinkingLevel", s.ThinkingLevel)
+	add("outputResolution", s.OutputResolution)
+
+	var safety []string
+	for _, setting := range s.SafetySettings {
+		safety = append(safety, setting.Category+"="+setting.Threshold)
+	}
+	add("safetySettings", strings.Join(safety, ", "))
+	add("tools", strings.Join(enabledTools(s), ", "))
+	add("systemInstruction", root.SystemInstruction.Content())
+
+	return fields
+}
+
+// FormatMetadata renders the metadata of root as "key: value" lines.
+func FormatMetadata(root Root) string {
+	var sb strings.Builder
+	for _, field := range Metadata(root) {
+		sb.WriteString(field.Key)
+		sb.WriteString(": ")
+		sb.WriteString(field.Value)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func enabledTools(s RunSettings) []string {
+	var tools []string
+	if s.EnableCodeExecution {
+		tools = append(tools, "codeExecution")
+	}
+	if s.EnableSearchAsATool {
+		tools = append(tools, "search")
+	}
+	if s.EnableBrowseAsATool {
+		tools = append(tools, "browse")
+	}
+	if s.EnableAutoFunctionResponse {
+		tools = append(tools, "autoFunctionResponse")
+	}
+	return tools
+}
@@ -1,14 +1,141 @@
 package exporter
 
+import "strings"
+
+// Chunk roles used by AI Studio.
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// roleName returns the display name of a chunk role, e.g. "User" for "user".
+func roleName(role string) string {
+	if role == "" {
+		return ""
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
+
+// Blob is binary data embedded in a prompt. Data is base64 encoded in JSON.
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+// DriveFile refers to a file stored in Google Drive.
+type DriveFile struct {
+	ID string `json:"id"`
+}
+
+// Part is a single piece of a chunk's content.
+type Part struct {
+	Text             string `json:"text"`
+	Thought          bool   `json:"thought,omitempty"`
+	ThoughtSignature string `json:"thoughtSignature,omitempty"`
+	InlineData       *Blob  `json:"inlineData,omitempty"`
+}
+
 type Chunk struct {
-	Text      string `json:"text"`
-	IsThought bool   `json:"isThought"`
+	Text          string     `json:"text"`
+	Role          string     `json:"role,omitempty"`
+	IsThought     bool       `json:"isThought"`
+	TokenCount    int        `json:"tokenCount,omitempty"`
+	FinishReason  string     `json:"finishReason,omitempty"`
+	Parts         []Part     `json:"parts,omitempty"`
+	InlineImage   *Blob      `json:"inlineImage,omitempty"`
+	InlineData    *Blob      `json:"inlineData,omitempty"`
+	DriveImage    *DriveFile `json:"driveImage,omitempty"`
+	DriveDocument *DriveFile `json:"driveDocument,omitempty"`
+}
+
+// Content returns the chunk text, falling back to the joined text of its
+// parts when the chunk-level text is empty.
+func (c Chunk) Content() string {
+	if c.Text != "" || len(c.Parts) == 0 {
+		return c.Text
+	}
+
+	var sb strings.Builder
+	for _, part := range c.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String()
+}
+
+// Attachment is a file attached to a chunk, either inline or in Drive.
+type Attachment struct {
+	// Kind is the field the attachment was found in, e.g. "inlineImage".
+	Kind   string
+	Inline *Blob
+	Drive  *DriveFile
+}
+
+// Attachments returns the files attached to the chunk and to its parts.
+func (c Chunk) Attachments() []Attachment {
+	var attachments []Attachment
+	if c.InlineImage != nil {
+		attachments = append(attachments, Attachment{Kind: "inlineImage", Inline: c.InlineImage})
+	}
+	if c.InlineData != nil {
+		attachments = append(attachments, Attachment{Kind: "inlineData", Inline: c.InlineData})
+	}
+	if c.DriveImage != nil {
+		attachments = append(attachments, Attachment{Kind: "driveImage", Drive: c.DriveImage})
+	}
+	if c.DriveDocument != nil {
+		attachments = append(attachments, Attachment{Kind: "driveDocument", Drive: c.DriveDocument})
+	}
+	for _, part := range c.Parts {
+		if part.InlineData != nil {
+			attachments = append(attachments, Attachment{Kind: "inlineData", Inline: part.InlineData})
+		}
+	}
+	return attachments
 }
 
 type ChunkedPrompt struct {
 	Chunks []Chunk `json:"chunks"`
 }
 
+// SafetySetting is a harm category and the threshold applied to it.
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// RunSettings holds the model configuration a prompt was run with.
+type RunSettings struct {
+	Model                      string          `json:"model,omitempty"`
+	Temperature                float64         `json:"temperature,omitempty"`
+	TopP                       float64         `json:"topP,omitempty"`
+	TopK                       int             `json:"topK,omitempty"`
+	MaxOutputTokens            int             `json:"maxOutputTokens,omitempty"`
+	SafetySettings             []SafetySetting `json:"safetySettings,omitempty"`
+	EnableCodeExecution        bool            `json:"enableCodeExecution"`
+	EnableSearchAsATool        bool            `json:"enableSearchAsATool"`
+	EnableBrowseAsATool        bool            `json:"enableBrowseAsATool"`
+	EnableAutoFunctionResponse bool            `json:"enableAutoFunctionResponse"`
+	OutputResolution           string          `json:"outputResolution,omitempty"`
+	ThinkingLevel              string          `json:"thinkingLevel,omitempty"`
+}
+
+// SystemInstruction is the system prompt of a conversation.
+type SystemInstruction struct {
+	Text  string `json:"text,omitempty"`
+	Parts []Part `json:"parts,omitempty"`
+}
+
+// Content returns the instruction text, falling back to the joined text of
+// its parts when the top-level text is empty.
+func (s SystemInstruction) Content() string {
+	return Chunk{Text: s.Text, Parts: s.Parts}.Content()
+}
+
 type Root struct {
-	ChunkedPrompt ChunkedPrompt `json:"chunkedPrompt"`
+	// Source identifies where the prompt was read from, e.g. its file path.
+	Source string `json:"-"`
+
+	RunSettings       RunSettings       `json:"runSettings"`
+	SystemInstruction SystemInstruction `json:"systemInstruction"`
+	ChunkedPrompt     ChunkedPrompt     `json:"chunkedPrompt"`
 }
@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ClobberMode selects what happens when an output file already exists.
+type ClobberMode int
+
+const (
+	// Overwrite replaces the existing file.
+	Overwrite ClobberMode = iota
+	// NoClobber fails the export and leaves the existing file untouched.
+	NoClobber
+	// Backup renames the existing file to BackupPath before replacing it.
+	Backup
+)
+
+// BackupPath returns the name an existing output at path is moved to by the
+// Backup mode.
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// outputFile is a temporary file in the directory of the output that is
+// renamed over it on commit, so readers never see a partially written
+// output and a failed export leaves a previous one intact.
+type outputFile struct {
+	*os.File
+	path string
+	mode ClobberMode
+}
+
+// createOutput creates a temporary file for the output at path. With
+// NoClobber it fails early if path already exists.
+func createOutput(path string, mode ClobberMode) (*outputFile, error) {
+	if mode == NoClobber {
+		if _, err := os.Lstat(path); err == nil {
+			return nil, existsError(path)
+		}
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error writing to output file: %w", err)
+	}
+
+	// Keep the permissions of the file being replaced, or use the usual
+	// ones for a new file rather than the private ones of a temp file.
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("error writing to output file: %w", err)
+	}
+
+	return &outputFile{File: file, path: path, mode: mode}, nil
+}
+
+// Commit flushes the temporary file to disk and moves it to the output path.
+func (f *outputFile) Commit() error {
+	err := f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = f.replace()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (f *outputFile) replace() error {
+	switch f.mode {
+	case NoClobber:
+		// Linking fails if the output appeared since createOutput.
+		if err := os.Link(f.Name(), f.path); err != nil {
+			if os.IsExist(err) {
+				return existsError(f.path)
+			}
+			return fmt.Errorf("error writing to output file: %w", err)
+		}
+		os.Remove(f.Name())
+		return nil
+	case Backup:
+		if err := os.Rename(f.path, BackupPath(f.path)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error backing up output file: %w", err)
+		}
+	}
+
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		return fmt.Errorf("error writing to output file: %w", err)
+	}
+	return nil
+}
+
+// Discard closes and removes the temporary file.
+func (f *outputFile) Discard() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+func existsError(path string) error {
+	return fmt.Errorf("error writing to output file: %w", &os.PathError{Op: "create", Path: path, Err: os.ErrExist})
+}
+
+// writeOutput writes content to out, or atomically to the file at path if
+// out is nil.
+func writeOutput(out io.Writer, path string, mode ClobberMode, content []byte) error {
+	if out != nil {
+		if _, err := out.Write(content); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+		return nil
+	}
+
+	file, err := createOutput(path, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Discard()
+		return fmt.Errorf("error writing to output file: %w", err)
+	}
+	return file.Commit()
+}
@@ -0,0 +1,125 @@
+package exporter
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteOutput_ClobberModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       ClobberMode
+		existing   bool
+		wantErr    bool
+		wantOutput string
+		wantBackup string
+	}{
+		{name: "Overwrite new", mode: Overwrite, wantOutput: "new"},
+		{name: "Overwrite existing", mode: Overwrite, existing: true, wantOutput: "new"},
+		{name: "NoClobber new", mode: NoClobber, wantOutput: "new"},
+		{name: "NoClobber existing", mode: NoClobber, existing: true, wantErr: true, wantOutput: "old"},
+		{name: "Backup new", mode: Backup, wantOutput: "new"},
+		{name: "Backup existing", mode: Backup, existing: true, wantOutput: "new", wantBackup: "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "output.txt")
+			if tt.existing {
+				if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := writeOutput(nil, path, tt.mode, []byte("new"))
+			if tt.wantErr {
+				if !errors.Is(err, fs.ErrExist) {
+					t.Errorf("Expected fs.ErrExist, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("writeOutput failed: %v", err)
+			}
+
+			if got, _ := os.ReadFile(path); string(got) != tt.wantOutput {
+				t.Errorf("Output = %q, want %q", got, tt.wantOutput)
+			}
+			backup, err := os.ReadFile(BackupPath(path))
+			if tt.wantBackup == "" && err == nil {
+				t.Errorf("Expected no backup, got %q", backup)
+			} else if tt.wantBackup != "" && string(backup) != tt.wantBackup {
+				t.Errorf("Backup = %q, want %q", backup, tt.wantBackup)
+			}
+
+			// No temporary files may be left behind.
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmp") {
+					t.Errorf("Temporary file %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteOutput_Permissions(t *testing.T) {
+	dir := t.TempDir()
+
+	newPath := filepath.Join(dir, "new.txt")
+	if err := writeOutput(nil, newPath, Overwrite, []byte("new")); err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	if info, err := os.Stat(newPath); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644 for a new file, got %v (err: %v)", info.Mode().Perm(), err)
+	}
+
+	existingPath := filepath.Join(dir, "existing.txt")
+	if err := os.WriteFile(existingPath, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOutput(nil, existingPath, Overwrite, []byte("new")); err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	if info, err := os.Stat(existingPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be kept, got %v (err: %v)", info.Mode().Perm(), err)
+	}
+}
+
+func TestTextWriter_AbortKeepsPreviousOutput(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(outputPath, []byte("previous export"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := `{"chunkedPrompt": {"chunks": [{"text": "One"}, {"te`
+	if err := ExportStream(strings.NewReader(input), "in", &TextWriter{OutputPath: outputPath}); err == nil {
+		t.Fatal("Expected error for truncated input, got nil")
+	}
+
+	if got, _ := os.ReadFile(outputPath); string(got) != "previous export" {
+		t.Errorf("Output = %q, want the previous export", got)
+	}
+}
+
+func TestTextWriter_NoClobber(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(outputPath, []byte("previous export"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := &TextWriter{OutputPath: outputPath, Clobber: NoClobber}
+	err := writer.Write(Root{ChunkedPrompt: ChunkedPrompt{Chunks: []Chunk{{Text: "New"}}}})
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Expected fs.ErrExist, got %v", err)
+	}
+	if got, _ := os.ReadFile(outputPath); string(got) != "previous export" {
+		t.Errorf("Output = %q, want the previous export", got)
+	}
+}
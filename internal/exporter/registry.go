@@ -0,0 +1,118 @@
+package exporter
+
+import (
+	"cmp"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WriterOptions configures the writer created for an export format. Each
+// format uses the options that apply to it and ignores the others.
+type WriterOptions struct {
+	OutputPath string
+	// Output, if set, receives the output instead of the file at OutputPath.
+	Output io.Writer
+	// Clobber selects what happens when the file at OutputPath already
+	// exists.
+	Clobber ClobberMode
+	// Metadata includes the run settings and system instruction.
+	Metadata bool
+	// Roles prefixes each chunk with a speaker label derived from its role;
+	// UserLabel and ModelLabel override the default labels.
+	Roles      bool
+	UserLabel  string
+	ModelLabel string
+	// Thoughts selects the thoughts to export.
+	Thoughts ThoughtMode
+	// PerTurn emits one example per user/model turn pair.
+	PerTurn bool
+	// Assets, if set, receives the attachments of the chunks.
+	Assets *AssetStore
+	// Blobs stores the content of inline attachments in a database.
+	Blobs bool
+	// Prices, if set, is used to store the estimated cost of each
+	// conversation.
+	Prices *PriceTable
+}
+
+// Factory creates the writers of an export format.
+type Factory struct {
+	// Extension is the file extension of the format's outputs, e.g. ".md",
+	// or "" for formats like databases that collect all inputs of a batch
+	// in one output.
+	Extension string
+	// Assets reports whether the format saves attachments to
+	// WriterOptions.Assets.
+	Assets bool
+	// New returns a writer with the given options. It fails if the options
+	// ask for something the format cannot do, such as writing a database to
+	// WriterOptions.Output.
+	New func(opts WriterOptions) (Writer, error)
+}
+
+// Format is a registered export format.
+type Format struct {
+	Name    string
+	Aliases []string
+	Factory
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = make(map[string]*Format)
+	// formatNames maps names and aliases to the name of their format.
+	formatNames = make(map[string]string)
+)
+
+// Register makes an export format available under name and its aliases,
+// which are case-insensitive. It panics if factory.New is nil or if a name
+// is already taken, so formats register once from an init function.
+func Register(name string, aliases []string, factory Factory) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	if factory.New == nil {
+		panic("exporter: Register format " + name + " without New")
+	}
+	name = strings.ToLower(name)
+	keys := []string{name}
+	for _, alias := range aliases {
+		keys = append(keys, strings.ToLower(alias))
+	}
+	for _, key := range keys {
+		if _, dup := formatNames[key]; dup {
+			panic("exporter: Register called twice for format " + key)
+		}
+	}
+	for _, key := range keys {
+		formatNames[key] = name
+	}
+	formats[name] = &Format{Name: name, Aliases: slices.Clone(aliases), Factory: factory}
+}
+
+// Lookup returns the format registered under name or one of its aliases.
+func Lookup(name string) (Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	format, ok := formats[formatNames[strings.ToLower(name)]]
+	if !ok {
+		return Format{}, false
+	}
+	return *format, true
+}
+
+// List returns the registered formats sorted by name.
+func List() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	list := make([]Format, 0, len(formats))
+	for _, format := range formats {
+		list = append(list, *format)
+	}
+	slices.SortFunc(list, func(a, b Format) int { return cmp.Compare(a.Name, b.Name) })
+	return list
+}
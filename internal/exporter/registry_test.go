@@ -0,0 +1,78 @@
+package exporter
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  string
+		extension string
+		writer    Writer
+	}{
+		{"txt", "txt", ".txt", &TextWriter{}},
+		{"Text", "txt", ".txt", &TextWriter{}},
+		{"markdown", "md", ".md", &MarkdownWriter{}},
+		{"html", "html", ".html", &HTMLWriter{}},
+		{"openai-jsonl", "openai-jsonl", ".jsonl", &OpenAIJSONLWriter{}},
+		{"gemini-jsonl", "gemini-jsonl", ".jsonl", &GeminiJSONLWriter{}},
+		{"db", "sqlite", "", &SQLiteWriter{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, ok := Lookup(tt.name)
+			if !ok {
+				t.Fatalf("Lookup(%q) found no format", tt.name)
+			}
+			if format.Name != tt.expected || format.Extension != tt.extension {
+				t.Errorf("Lookup(%q) = %s with extension %q, want %s with %q", tt.name, format.Name, format.Extension, tt.expected, tt.extension)
+			}
+			writer, err := format.New(WriterOptions{OutputPath: "out"})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			if reflect.TypeOf(writer) != reflect.TypeOf(tt.writer) {
+				t.Errorf("New returned %T, want %T", writer, tt.writer)
+			}
+		})
+	}
+
+	if _, ok := Lookup("pdf"); ok {
+		t.Error("Expected no format for pdf")
+	}
+}
+
+func TestList(t *testing.T) {
+	var names []string
+	for _, format := range List() {
+		names = append(names, format.Name)
+	}
+	expected := []string{"gemini-jsonl", "html", "md", "openai-jsonl", "sqlite", "txt"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("List() = %v, want %v", names, expected)
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Register to panic for a taken alias")
+		}
+		if _, ok := Lookup("text-copy"); ok {
+			t.Error("Expected the failed registration to leave no format")
+		}
+	}()
+	Register("text-copy", []string{"TEXT"}, Factory{New: func(WriterOptions) (Writer, error) { return &TextWriter{}, nil }})
+}
+
+func TestSQLiteFormat_Stdout(t *testing.T) {
+	format, _ := Lookup("sqlite")
+	if _, err := format.New(WriterOptions{Output: new(bytes.Buffer)}); err == nil {
+		t.Error("Expected error writing a database to standard output, got nil")
+	}
+}
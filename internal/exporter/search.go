@@ -0,0 +1,130 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// Default snippet markers used by Search.
+const (
+	DefaultHighlightStart = "**"
+	DefaultHighlightEnd   = "**"
+)
+
+// SearchOptions narrows and formats the results of Search.
+type SearchOptions struct {
+	// Roles restricts results to messages with one of the given roles.
+	Roles []string
+	// Limit caps the number of results; zero means no limit.
+	Limit int
+	// HighlightStart and HighlightEnd surround matched terms in snippets.
+	HighlightStart string
+	HighlightEnd   string
+}
+
+// SearchResult is a message matching a search query.
+type SearchResult struct {
+	ConversationID uint   `json:"conversationId"`
+	SourcePath     string `json:"sourcePath"`
+	MessageID      uint   `json:"messageId"`
+	// Ordinal is the 0-based index of the message's chunk in the input.
+	Ordinal int `json:"ordinal"`
+	// Turn is the 1-based number of the message's turn, as selected by
+	// TurnFilter, counted over the stored messages of the conversation.
+	Turn    int     `json:"turn"`
+	Role    string  `json:"role"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// snippetTokens is the maximum number of tokens in a result snippet.
+const snippetTokens = 16
+
+// Search runs an FTS5 query against the messages of the database at dbPath
+// and returns the matches ordered by relevance.
+func Search(dbPath, query string, opts SearchOptions) ([]SearchResult, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDB(db)
+
+	if !db.Migrator().HasTable("messages_fts") {
+		return nil, errors.New("error searching: database has no search index, re-export into it to create one")
+	}
+
+	start := valueOr(opts.HighlightStart, DefaultHighlightStart)
+	end := valueOr(opts.HighlightEnd, DefaultHighlightEnd)
+
+	tx := db.Table("messages_fts").
+		Select(`m.conversation_id, c.source_path, m.id AS message_id, m.ordinal, m.role,
+			snippet(messages_fts, 0, ?, ?, '…', ?) AS snippet, bm25(messages_fts) AS rank`, start, end, snippetTokens).
+		Joins("JOIN messages m ON m.id = messages_fts.rowid").
+		Joins("JOIN conversations c ON c.id = m.conversation_id").
+		Where("messages_fts MATCH ?", query).
+		Order("rank")
+	if len(opts.Roles) > 0 {
+		tx = tx.Where("m.role IN ?", opts.Roles)
+	}
+	if opts.Limit > 0 {
+		tx = tx.Limit(opts.Limit)
+	}
+
+	var results []SearchResult
+	if err := tx.Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("error searching: %w", err)
+	}
+	if err := numberTurns(db, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// numberTurns sets the Turn of each result from the roles of the messages
+// of its conversation, starting a new turn whenever the role changes.
+func numberTurns(db *gorm.DB, results []SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	var conversations []uint
+	seen := make(map[uint]bool)
+	for _, result := range results {
+		if !seen[result.ConversationID] {
+			seen[result.ConversationID] = true
+			conversations = append(conversations, result.ConversationID)
+		}
+	}
+
+	var messages []MessageRecord
+	err := db.Select("id", "conversation_id", "role").Where("conversation_id IN ?", conversations).
+		Order("conversation_id, ordinal, id").Find(&messages).Error
+	if err != nil {
+		return fmt.Errorf("error searching: %w", err)
+	}
+
+	turns := make(map[uint]int)
+	var conversation uint
+	var role string
+	turn := 0
+	for _, message := range messages {
+		if message.ConversationID != conversation {
+			conversation, turn = message.ConversationID, 0
+		}
+		if turn == 0 || message.Role != role {
+			turn++
+			role = message.Role
+		}
+		turns[message.ID] = turn
+	}
+	for i := range results {
+		results[i].Turn = turns[results[i].MessageID]
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func searchDB(t *testing.T) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	roots := []Root{
+		{
+			Source: "first.json",
+			ChunkedPrompt: ChunkedPrompt{
+				Chunks: []Chunk{
+					{Text: "How do I parse JSON in Go?", Role: RoleUser},
+					{Text: "Use encoding/json to parse JSON. JSON decoding is simple.", Role: RoleModel},
+				},
+			},
+		},
+		{
+			Source: "second.json",
+			ChunkedPrompt: ChunkedPrompt{
+				Chunks: []Chunk{
+					{Text: "Write a haiku", Role: RoleUser},
+					{Text: "Autumn moonlight, a worm digs silently into the JSON", Role: RoleModel},
+				},
+			},
+		},
+	}
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	for _, root := range roots {
+		if err := writer.Write(root); err != nil {
+			t.Fatalf("SQLiteWriter.Write failed: %v", err)
+		}
+	}
+	return dbPath
+}
+
+func TestSearch(t *testing.T) {
+	dbPath := searchDB(t)
+
+	results, err := Search(dbPath, "json", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d: %+v", len(results), results)
+	}
+
+	// The message mentioning JSON most often ranks first.
+	best := results[0]
+	if best.SourcePath != "first.json" || best.Ordinal != 1 || best.Turn != 2 || best.Role != RoleModel {
+		t.Errorf("Unexpected best result: %+v", best)
+	}
+	if best.Snippet != "Use encoding/**json** to parse **JSON**. **JSON** decoding is simple." {
+		t.Errorf("Unexpected snippet: %q", best.Snippet)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Rank < results[i-1].Rank {
+			t.Errorf("Results not ordered by rank: %+v", results)
+		}
+	}
+}
+
+func TestSearch_Turn(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	root := Root{Source: "turns.json", ChunkedPrompt: ChunkedPrompt{Chunks: []Chunk{
+		{Text: "Hi", Role: RoleUser},
+		{Text: "Thinking", Role: RoleModel, IsThought: true},
+		{Text: "Hello", Role: RoleModel},
+		{Text: "Tell me about gophers", Role: RoleUser},
+		{Text: "Gophers burrow", Role: RoleModel},
+	}}}
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+	writer.Close()
+
+	results, err := Search(dbPath, "gophers", SearchOptions{Roles: []string{RoleModel}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Ordinal != 4 || results[0].Turn != 4 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestSearch_Options(t *testing.T) {
+	dbPath := searchDB(t)
+
+	tests := []struct {
+		name    string
+		opts    SearchOptions
+		count   int
+		snippet string
+	}{
+		{"Role filter", SearchOptions{Roles: []string{RoleUser}}, 1, "How do I parse **JSON** in Go?"},
+		{"Limit", SearchOptions{Limit: 1}, 1, "Use encoding/**json** to parse **JSON**. **JSON** decoding is simple."},
+		{"Highlight", SearchOptions{Roles: []string{RoleUser}, HighlightStart: "<", HighlightEnd: ">"}, 1, "How do I parse <JSON> in Go?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Search(dbPath, "json", tt.opts)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results) != tt.count {
+				t.Fatalf("Expected %d results, got %d", tt.count, len(results))
+			}
+			if results[0].Snippet != tt.snippet {
+				t.Errorf("Snippet = %q, want %q", results[0].Snippet, tt.snippet)
+			}
+		})
+	}
+}
+
+func TestSearch_Errors(t *testing.T) {
+	dbPath := searchDB(t)
+
+	if _, err := Search(dbPath, `"unbalanced`, SearchOptions{}); err == nil {
+		t.Error("Expected error for invalid query, got nil")
+	}
+
+	if _, err := Search(filepath.Join(t.TempDir(), "missing.db"), "json", SearchOptions{}); err == nil {
+		t.Error("Expected error for missing database, got nil")
+	}
+}
+
+func TestSQLiteWriter_RebuildsSearchIndex(t *testing.T) {
+	dbPath := searchDB(t)
+
+	// Simulate a database written before the search index existed.
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"DROP TRIGGER messages_fts_insert",
+		"DROP TRIGGER messages_fts_delete",
+		"DROP TRIGGER messages_fts_update",
+		"DROP TABLE messages_fts",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := Search(dbPath, "json", SearchOptions{}); err == nil {
+		t.Error("Expected error for database without search index, got nil")
+	}
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := writer.Write(Root{}); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	results, err := Search(dbPath, "haiku", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].SourcePath != "second.json" {
+		t.Errorf("Expected existing messages to be indexed, got %+v", results)
+	}
+}
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// sqliteHeader starts every SQLite database file.
+const sqliteHeader = "SQLite format 3\x00"
+
+// IsDatabase reports whether the file at path is a SQLite database.
+func IsDatabase(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, []byte(sqliteHeader))
+}
+
+// ReadDatabase reads the conversations of a database written by
+// SQLiteWriter and passes them to fn one at a time, in the order they were
+// imported. Each conversation is rebuilt with its source path, run settings,
+// system instruction and messages; thoughts are included as far as they
+// were exported to the database, from the messages or the thoughts table.
+// Parts and attachments are not restored.
+func ReadDatabase(dbPath string, fn func(Root) error) error {
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("error opening database: %w", err)
+	}
+	db, err := openDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+
+	if !db.Migrator().HasTable(&ConversationRecord{}) {
+		return fmt.Errorf("error reading database: %s has no conversations table", dbPath)
+	}
+	hasThoughts := db.Migrator().HasTable(&ThoughtRecord{})
+
+	var conversations []ConversationRecord
+	if err := db.Order("id").Find(&conversations).Error; err != nil {
+		return fmt.Errorf("error reading conversations: %w", err)
+	}
+
+	for _, conversation := range conversations {
+		root := Root{
+			Source:            conversation.SourcePath,
+			SystemInstruction: SystemInstruction{Text: conversation.SystemInstruction},
+		}
+		if conversation.Settings != "" {
+			if err := json.Unmarshal([]byte(conversation.Settings), &root.RunSettings); err != nil {
+				return fmt.Errorf("error reading settings of %s: %w", conversation.SourcePath, err)
+			}
+		}
+		if root.RunSettings.Model == "" {
+			root.RunSettings.Model = conversation.Model
+		}
+
+		var messages []MessageRecord
+		if err := db.Where("conversation_id = ?", conversation.ID).Order("ordinal, id").Find(&messages).Error; err != nil {
+			return fmt.Errorf("error reading messages: %w", err)
+		}
+		var thoughts []ThoughtRecord
+		if hasThoughts {
+			if err := db.Where("conversation_id = ?", conversation.ID).Order("ordinal, id").Find(&thoughts).Error; err != nil {
+				return fmt.Errorf("error reading thoughts: %w", err)
+			}
+		}
+
+		type ordered struct {
+			ordinal int
+			chunk   Chunk
+		}
+		var chunks []ordered
+		for _, thought := range thoughts {
+			chunks = append(chunks, ordered{thought.Ordinal, Chunk{Text: thought.Text, Role: thought.Role, IsThought: true, TokenCount: thought.TokenCount}})
+		}
+		for _, message := range messages {
+			chunks = append(chunks, ordered{message.Ordinal, Chunk{
+				Text:         message.Text,
+				Role:         message.Role,
+				IsThought:    message.IsThought,
+				TokenCount:   message.TokenCount,
+				FinishReason: message.FinishReason,
+			}})
+		}
+		// The thoughts of a chunk come before its answer.
+		slices.SortStableFunc(chunks, func(a, b ordered) int { return cmp.Compare(a.ordinal, b.ordinal) })
+		for _, c := range chunks {
+			root.ChunkedPrompt.Chunks = append(root.ChunkedPrompt.Chunks, c.chunk)
+		}
+
+		if err := fn(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
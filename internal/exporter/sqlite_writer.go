@@ -1,52 +1,868 @@
 package exporter
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	_ "modernc.org/sqlite"
 )
 
-// ChunkRecord represents a chunk record in the database.
+// ConversationRecord represents an imported conversation in the database.
+type ConversationRecord struct {
+	ID                uint `gorm:"primaryKey"`
+	SourcePath        string
+	Model             string
+	Settings          string
+	SystemInstruction string
+	ImportedAt        time.Time
+	// Cost is the estimated cost of the conversation, if the writer was
+	// given prices for its model.
+	Cost *float64
+	// Hash identifies the content of the conversation; see conversationHash.
+	Hash        string             `gorm:"index"`
+	Messages    []MessageRecord    `gorm:"foreignKey:ConversationID;constraint:OnDelete:CASCADE"`
+	Thoughts    []ThoughtRecord    `gorm:"foreignKey:ConversationID;constraint:OnDelete:CASCADE"`
+	Attachments []AttachmentRecord `gorm:"foreignKey:ConversationID;constraint:OnDelete:CASCADE"`
+}
+
+// TableName keeps the table name independent of the struct name.
+func (ConversationRecord) TableName() string {
+	return "conversations"
+}
+
+// MessageRecord represents a chunk of a conversation in the database.
+type MessageRecord struct {
+	ID             uint `gorm:"primaryKey"`
+	ConversationID uint `gorm:"not null;index"`
+	Ordinal        int  `gorm:"not null"`
+	Role           string
+	IsThought      bool `gorm:"not null;default:false"`
+	TokenCount     int
+	FinishReason   string
+	Text           string `gorm:"not null"`
+	// Hash identifies the message together with all messages before it;
+	// see messageHash.
+	Hash  string       `gorm:"index"`
+	Parts []PartRecord `gorm:"foreignKey:MessageID;constraint:OnDelete:CASCADE"`
+}
+
+// TableName keeps the table name independent of the struct name.
+func (MessageRecord) TableName() string {
+	return "messages"
+}
+
+// PartRecord represents a part of a message in the database.
+type PartRecord struct {
+	ID               uint `gorm:"primaryKey"`
+	MessageID        uint `gorm:"not null;index"`
+	Ordinal          int  `gorm:"not null"`
+	Text             string
+	Thought          bool `gorm:"not null;default:false"`
+	ThoughtSignature string
+}
+
+// TableName keeps the table name independent of the struct name.
+func (PartRecord) TableName() string {
+	return "parts"
+}
+
+// ThoughtRecord represents a thought of a conversation stored apart from its
+// messages, as written in SeparateThoughts mode.
+type ThoughtRecord struct {
+	ID             uint `gorm:"primaryKey"`
+	ConversationID uint `gorm:"not null;index"`
+	Ordinal        int  `gorm:"not null"`
+	Role           string
+	TokenCount     int
+	Text           string `gorm:"not null"`
+}
+
+// TableName keeps the table name independent of the struct name.
+func (ThoughtRecord) TableName() string {
+	return "thoughts"
+}
+
+// AttachmentRecord represents a file attached to a message in the database.
+// Inline attachments are identified by the SHA-256 of their content, Drive
+// attachments by their Drive ID.
+type AttachmentRecord struct {
+	ID             uint `gorm:"primaryKey"`
+	ConversationID uint `gorm:"not null;index"`
+	// Ordinal is the ordinal of the message the attachment belongs to.
+	Ordinal  int    `gorm:"not null"`
+	Kind     string `gorm:"not null"`
+	MimeType string
+	SHA256   string `gorm:"column:sha256;index"`
+	Size     int
+	// Path is the file in the asset directory holding the attachment, if
+	// it was saved to one.
+	Path    string
+	DriveID string
+	// Data holds the content of an inline attachment if blobs are stored.
+	Data []byte
+}
+
+// TableName keeps the table name independent of the struct name.
+func (AttachmentRecord) TableName() string {
+	return "attachments"
+}
+
+// ChunkRecord represents a row of the chunk_records compatibility view, which
+// lists the non-thought messages of all conversations.
 type ChunkRecord struct {
-	ID   uint   `gorm:"primaryKey"`
-	Text string `gorm:"not null"`
+	ID           uint `gorm:"primaryKey"`
+	Role         string
+	TokenCount   int
+	FinishReason string
+	Text         string `gorm:"not null"`
+}
+
+const chunkRecordsView = `CREATE VIEW IF NOT EXISTS chunk_records AS
+SELECT id, role, token_count, finish_reason, text FROM messages WHERE is_thought = 0`
+
+// messagesFTS is an external-content FTS5 index over messages.text, kept in
+// sync with the messages table by triggers.
+var messagesFTS = []string{
+	`CREATE VIRTUAL TABLE messages_fts USING fts5(text, content='messages', content_rowid='id')`,
+	`CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+	INSERT INTO messages_fts(rowid, text) VALUES (new.id, new.text);
+END`,
+	`CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+	INSERT INTO messages_fts(messages_fts, rowid, text) VALUES ('delete', old.id, old.text);
+END`,
+	`CREATE TRIGGER messages_fts_update AFTER UPDATE OF text ON messages BEGIN
+	INSERT INTO messages_fts(messages_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	INSERT INTO messages_fts(rowid, text) VALUES (new.id, new.text);
+END`,
+	// Index messages written before the index existed.
+	`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`,
+}
+
+// ImportStats counts the conversations written to a database.
+type ImportStats struct {
+	// Inserted counts conversations added as new.
+	Inserted int
+	// Updated counts existing conversations that received new messages or
+	// changed run settings.
+	Updated int
+	// Skipped counts conversations that were already stored.
+	Skipped int
+	// Messages counts the messages added.
+	Messages int
+}
+
+func (s *ImportStats) add(other ImportStats) {
+	s.Inserted += other.Inserted
+	s.Updated += other.Updated
+	s.Skipped += other.Skipped
+	s.Messages += other.Messages
+}
+
+func init() {
+	Register("sqlite", []string{"db"}, Factory{Assets: true, New: func(opts WriterOptions) (Writer, error) {
+		if opts.Output != nil {
+			return nil, fmt.Errorf("the sqlite format cannot write to standard output")
+		}
+		return &SQLiteWriter{DBPath: opts.OutputPath, Thoughts: opts.Thoughts, Assets: opts.Assets, Blobs: opts.Blobs, Prices: opts.Prices}, nil
+	}})
 }
 
 // SQLiteWriter writes chunks to a SQLite database using GORM.
+//
+// Imports are idempotent: a conversation whose messages are already stored
+// is skipped, and one that extends a stored conversation, such as a prompt
+// file that gained new turns, only adds the new messages to it.
+//
+// The database stays open across exports; call Close when done.
 type SQLiteWriter struct {
 	DBPath string
+	// Thoughts selects the thoughts to store. Included thoughts are stored
+	// as messages with is_thought set; separate ones go to the thoughts
+	// table.
+	Thoughts ThoughtMode
+	// Assets, if set, receives the inline attachments of the chunks, whose
+	// paths are stored in the attachments table.
+	Assets *AssetStore
+	// Blobs stores the content of inline attachments in the attachments
+	// table.
+	Blobs bool
+	// Prices, if set, is used to store the estimated cost of each
+	// conversation; see Cost.
+	Prices *PriceTable
+	// Stats accumulates the outcome of every export made with the writer.
+	Stats ImportStats
+
+	db *gorm.DB
+	tx *gorm.DB
+	// conversation is the conversation being written; it is only stored
+	// once a message differs from every stored conversation.
+	conversation ConversationRecord
+	ordinal      int
+	// hash is the messageHash of the last message written.
+	hash string
+	// match is the stored conversation whose first matched messages equal
+	// the messages written so far.
+	match   uint
+	matched int
+	// target is the conversation that receives new messages, or 0 while
+	// the messages written so far are all stored already.
+	target uint
+	stats  ImportStats
+	// thoughts holds the thoughts to store in SeparateThoughts mode until
+	// the conversation they belong to is known.
+	thoughts []ThoughtRecord
+	// attachments holds the attachments until the conversation they belong
+	// to is known.
+	attachments []AttachmentRecord
+	cost        costCounter
 }
 
-// Write writes the chunks to a SQLite database.
+// Write writes the chunks to a SQLite database as a new conversation.
 func (w *SQLiteWriter) Write(root Root) error {
-	db, err := gorm.Open(sqlite.Open(w.DBPath), &gorm.Config{})
+	return w.WriteContext(context.Background(), root)
+}
+
+// WriteContext is like Write but stops when ctx is cancelled, rolling back
+// the conversation.
+func (w *SQLiteWriter) WriteContext(ctx context.Context, root Root) error {
+	return writeDocument(ctx, w, root)
+}
+
+// Begin starts a transaction that adds a new conversation described by meta.
+// The database is opened and migrated on first use and stays open until
+// Close.
+func (w *SQLiteWriter) Begin(meta Root) error {
+	return w.BeginContext(context.Background(), meta)
+}
+
+// BeginContext is like Begin but runs the transaction under ctx, so that
+// cancelling ctx interrupts pending statements and rolls the conversation
+// back.
+func (w *SQLiteWriter) BeginContext(ctx context.Context, meta Root) error {
+	if err := w.open(ctx); err != nil {
+		return err
+	}
+
+	conversation, err := newConversationRecord(meta)
 	if err != nil {
-		return fmt.Errorf("error opening database: %w", err)
+		return err
 	}
 
-	if err := db.AutoMigrate(&ChunkRecord{}); err != nil {
-		return fmt.Errorf("error migrating database: %w", err)
+	tx := w.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return fmt.Errorf("error starting transaction: %w", tx.Error)
 	}
 
-	records := make([]ChunkRecord, 0, len(root.ChunkedPrompt.Chunks))
-	for _, chunk := range root.ChunkedPrompt.Chunks {
-		if chunk.IsThought {
+	w.tx = tx
+	w.conversation = conversation
+	w.ordinal = 0
+	w.hash = ""
+	w.match, w.matched, w.target = 0, 0, 0
+	w.stats = ImportStats{}
+	w.thoughts = nil
+	w.attachments = nil
+	w.cost = newCostCounter(meta)
+	return nil
+}
+
+// open opens and migrates the database unless it is open already.
+func (w *SQLiteWriter) open(ctx context.Context) error {
+	if w.db != nil {
+		return nil
+	}
+	db, err := openDB(w.DBPath)
+	if err != nil {
+		return err
+	}
+	if err := migrate(db.WithContext(ctx)); err != nil {
+		closeDB(db)
+		return err
+	}
+	w.db = db
+	return nil
+}
+
+// WriteChunk inserts a chunk selected by Thoughts as the next message of
+// the conversation, unless it is already stored.
+func (w *SQLiteWriter) WriteChunk(chunk Chunk) error {
+	ordinal := w.ordinal
+	w.ordinal++
+	w.cost.add(chunk)
+
+	if err := w.addAttachments(ordinal, chunk); err != nil {
+		return err
+	}
+
+	for _, piece := range contentPieces(chunk, false) {
+		text := piece.Content()
+		if piece.IsThought && w.Thoughts == SeparateThoughts {
+			w.thoughts = append(w.thoughts, ThoughtRecord{
+				Ordinal:    ordinal,
+				Role:       piece.Role,
+				TokenCount: piece.TokenCount,
+				Text:       text,
+			})
 			continue
 		}
-		if chunk.Text != "" {
-			records = append(records, ChunkRecord{
-				Text: chunk.Text,
-			})
+		if !w.Thoughts.keeps(piece.IsThought) {
+			continue
+		}
+		if err := w.writeMessage(newMessageRecord(ordinal, piece, text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addAttachments holds back the attachments of the chunk at ordinal, saving
+// them to Assets if it is set.
+func (w *SQLiteWriter) addAttachments(ordinal int, chunk Chunk) error {
+	for _, attachment := range chunk.Attachments() {
+		asset, data := newAsset(w.conversation.SourcePath, attachment)
+		if w.Assets != nil {
+			if err := w.Assets.save(asset, data); err != nil {
+				return err
+			}
+		} else {
+			asset.Path = ""
+		}
+		if !w.Blobs {
+			data = nil
+		}
+
+		w.attachments = append(w.attachments, AttachmentRecord{
+			Ordinal:  ordinal,
+			Kind:     asset.Kind,
+			MimeType: asset.MimeType,
+			SHA256:   asset.SHA256,
+			Size:     asset.Size,
+			Path:     asset.Path,
+			DriveID:  asset.DriveID,
+			Data:     data,
+		})
+	}
+	return nil
+}
+
+func (w *SQLiteWriter) writeMessage(message MessageRecord) error {
+	w.hash = messageHash(w.hash, message)
+	message.Hash = w.hash
+
+	if w.target == 0 {
+		stored, err := w.matchMessage(message)
+		if err != nil {
+			return err
+		}
+		if stored {
+			return nil
+		}
+		if err := w.diverge(); err != nil {
+			return err
+		}
+	}
+
+	message.ConversationID = w.target
+	if err := w.tx.Create(&message).Error; err != nil {
+		return fmt.Errorf("error inserting chunks: %w", err)
+	}
+	w.stats.Messages++
+	return nil
+}
+
+// matchMessage reports whether message is stored at the same position of
+// the conversation matched so far, preferring a conversation imported from
+// the same source for the first message.
+func (w *SQLiteWriter) matchMessage(message MessageRecord) (bool, error) {
+	var ids []uint
+	err := w.tx.Raw(`SELECT m.conversation_id FROM messages m JOIN conversations c ON c.id = m.conversation_id
+WHERE m.hash = ? AND m.ordinal = ? AND (? = 0 OR m.conversation_id = ?)
+ORDER BY c.source_path = ? DESC, m.conversation_id LIMIT 1`,
+		message.Hash, message.Ordinal, w.match, w.match, w.conversation.SourcePath).Scan(&ids).Error
+	if err != nil {
+		return false, fmt.Errorf("error matching stored messages: %w", err)
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	w.match = ids[0]
+	w.matched++
+	return true, nil
+}
+
+// diverge chooses the conversation for the first message that is not stored
+// yet. If the matched conversation comes from the same source and every one
+// of its messages has been seen, the source has grown and new messages are
+// appended to it. Otherwise a new conversation is created, starting with a
+// copy of the matched messages.
+func (w *SQLiteWriter) diverge() error {
+	if w.match != 0 {
+		var stored int64
+		err := w.tx.Model(&MessageRecord{}).
+			Joins("JOIN conversations ON conversations.id = messages.conversation_id").
+			Where("messages.conversation_id = ? AND conversations.source_path = ?", w.match, w.conversation.SourcePath).
+			Count(&stored).Error
+		if err != nil {
+			return fmt.Errorf("error matching stored messages: %w", err)
+		}
+		if int(stored) == w.matched {
+			w.target = w.match
+			return nil
+		}
+	}
+
+	if err := w.tx.Create(&w.conversation).Error; err != nil {
+		return fmt.Errorf("error inserting conversation: %w", err)
+	}
+	w.target = w.conversation.ID
+
+	if w.matched == 0 {
+		return nil
+	}
+	var messages []MessageRecord
+	err := w.tx.Preload("Parts").Where("conversation_id = ?", w.match).
+		Order("ordinal, id").Limit(w.matched).Find(&messages).Error
+	if err != nil {
+		return fmt.Errorf("error copying stored messages: %w", err)
+	}
+	for i := range messages {
+		messages[i].ID = 0
+		messages[i].ConversationID = w.target
+		for j := range messages[i].Parts {
+			messages[i].Parts[j].ID = 0
+			messages[i].Parts[j].MessageID = 0
+		}
+	}
+	if err := w.tx.Create(&messages).Error; err != nil {
+		return fmt.Errorf("error copying stored messages: %w", err)
+	}
+	w.stats.Messages += len(messages)
+	return nil
+}
+
+// updateMeta takes the run settings and system instruction that follow the
+// chunks. A conversation already inserted receives them in finish.
+func (w *SQLiteWriter) updateMeta(meta Root) error {
+	conversation, err := newConversationRecord(meta)
+	if err != nil {
+		return err
+	}
+	w.conversation.Model = conversation.Model
+	w.conversation.Settings = conversation.Settings
+	w.conversation.SystemInstruction = conversation.SystemInstruction
+	w.cost.updateMeta(meta)
+	return nil
+}
+
+// End stores or updates the conversation and commits it.
+func (w *SQLiteWriter) End() error {
+	id, err := w.finish()
+	if err == nil && len(w.thoughts) > 0 {
+		err = w.storeThoughts(id)
+	}
+	if err == nil && len(w.attachments) > 0 {
+		err = w.storeAttachments(id)
+	}
+	if err == nil && w.Prices != nil {
+		err = w.storeCost(id)
+	}
+	if err == nil {
+		if err = w.tx.Commit().Error; err != nil {
+			err = fmt.Errorf("error inserting chunks: %w", err)
+		}
+	} else {
+		w.tx.Rollback()
+	}
+	w.tx = nil
+
+	if err != nil {
+		return err
+	}
+	w.Stats.add(w.stats)
+	return nil
+}
+
+// finish records the hash of the conversation, decides how it counts in the
+// import statistics and returns the ID of the stored conversation.
+func (w *SQLiteWriter) finish() (uint, error) {
+	hash := conversationHash(w.conversation, w.hash)
+
+	switch {
+	case w.target != 0 && w.target != w.match:
+		w.stats.Inserted++
+		err := w.tx.Model(&ConversationRecord{}).Where("id = ?", w.target).Updates(map[string]any{
+			"model":              w.conversation.Model,
+			"settings":           w.conversation.Settings,
+			"system_instruction": w.conversation.SystemInstruction,
+			"hash":               hash,
+		}).Error
+		if err != nil {
+			return 0, fmt.Errorf("error updating conversation: %w", err)
+		}
+		return w.target, nil
+	case w.target == 0 && w.match == 0:
+		// A conversation without messages is only identified by its hash.
+		var stored []uint
+		if err := w.tx.Model(&ConversationRecord{}).Where("hash = ?", hash).Limit(1).Pluck("id", &stored).Error; err != nil {
+			return 0, fmt.Errorf("error matching stored conversations: %w", err)
+		}
+		if len(stored) > 0 {
+			w.stats.Skipped++
+			return stored[0], nil
+		}
+		w.conversation.Hash = hash
+		if err := w.tx.Create(&w.conversation).Error; err != nil {
+			return 0, fmt.Errorf("error inserting conversation: %w", err)
+		}
+		w.stats.Inserted++
+		return w.conversation.ID, nil
+	}
+
+	var stored ConversationRecord
+	if err := w.tx.First(&stored, w.match).Error; err != nil {
+		return 0, fmt.Errorf("error matching stored conversations: %w", err)
+	}
+	if w.target == 0 {
+		// Every message is stored: the input is unchanged, or it is an
+		// earlier version of a conversation that has grown since.
+		var messages int64
+		if err := w.tx.Model(&MessageRecord{}).Where("conversation_id = ?", w.match).Count(&messages).Error; err != nil {
+			return 0, fmt.Errorf("error matching stored conversations: %w", err)
+		}
+		if stored.Hash == hash || int(messages) > w.matched {
+			w.stats.Skipped++
+			return stored.ID, nil
+		}
+	}
+
+	w.stats.Updated++
+	err := w.tx.Model(&stored).Updates(map[string]any{
+		"model":              w.conversation.Model,
+		"settings":           w.conversation.Settings,
+		"system_instruction": w.conversation.SystemInstruction,
+		"hash":               hash,
+	}).Error
+	if err != nil {
+		return 0, fmt.Errorf("error updating conversation: %w", err)
+	}
+	return stored.ID, nil
+}
+
+// storeThoughts adds the thoughts held back in SeparateThoughts mode to the
+// conversation with the given ID, skipping those it already has.
+func (w *SQLiteWriter) storeThoughts(id uint) error {
+	var stored []ThoughtRecord
+	if err := w.tx.Where("conversation_id = ?", id).Find(&stored).Error; err != nil {
+		return fmt.Errorf("error inserting thoughts: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, thought := range stored {
+		seen[strconv.Itoa(thought.Ordinal)+":"+thought.Text] = true
+	}
+
+	var thoughts []ThoughtRecord
+	for _, thought := range w.thoughts {
+		if !seen[strconv.Itoa(thought.Ordinal)+":"+thought.Text] {
+			thought.ConversationID = id
+			thoughts = append(thoughts, thought)
+		}
+	}
+	if len(thoughts) == 0 {
+		return nil
+	}
+	if err := w.tx.Create(&thoughts).Error; err != nil {
+		return fmt.Errorf("error inserting thoughts: %w", err)
+	}
+	return nil
+}
+
+// storeAttachments adds the attachments held back to the conversation with
+// the given ID, skipping those it already has.
+func (w *SQLiteWriter) storeAttachments(id uint) error {
+	var stored []AttachmentRecord
+	if err := w.tx.Omit("data").Where("conversation_id = ?", id).Find(&stored).Error; err != nil {
+		return fmt.Errorf("error inserting attachments: %w", err)
+	}
+	key := func(a AttachmentRecord) string {
+		return strconv.Itoa(a.Ordinal) + ":" + a.Kind + ":" + a.SHA256 + ":" + a.DriveID
+	}
+	seen := make(map[string]bool)
+	for _, attachment := range stored {
+		seen[key(attachment)] = true
+	}
+
+	var attachments []AttachmentRecord
+	for _, attachment := range w.attachments {
+		if !seen[key(attachment)] {
+			seen[key(attachment)] = true
+			attachment.ConversationID = id
+			attachments = append(attachments, attachment)
+		}
+	}
+	if len(attachments) == 0 {
+		return nil
+	}
+	if err := w.tx.Create(&attachments).Error; err != nil {
+		return fmt.Errorf("error inserting attachments: %w", err)
+	}
+	return nil
+}
+
+// storeCost records the estimated cost of the conversation with the given
+// ID, or clears it if its model has no price.
+func (w *SQLiteWriter) storeCost(id uint) error {
+	// An unpriced model clears the cost of an earlier export.
+	var total *float64
+	if cost := w.cost.result(*w.Prices); cost.Priced {
+		total = &cost.Total
+	}
+	if err := w.tx.Model(&ConversationRecord{}).Where("id = ?", id).Update("cost", total).Error; err != nil {
+		return fmt.Errorf("error updating conversation: %w", err)
+	}
+	return nil
+}
+
+// Abort rolls back the conversation.
+func (w *SQLiteWriter) Abort() {
+	if w.tx == nil {
+		return
+	}
+	w.tx.Rollback()
+	w.tx = nil
+}
+
+// Close rolls back a conversation in progress and closes the database. The
+// writer opens the database again if it is used afterwards.
+func (w *SQLiteWriter) Close() error {
+	w.Abort()
+	if w.db == nil {
+		return nil
+	}
+	db := w.db
+	w.db = nil
+
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error closing database: %w", err)
+	}
+	return nil
+}
+
+// openDB opens the SQLite database at path with foreign keys enforced.
+func openDB(path string) (*gorm.DB, error) {
+	// The driver truncates paths at a NUL byte, which would silently open a
+	// different file than the one requested.
+	if strings.ContainsRune(path, 0) {
+		return nil, fmt.Errorf("error opening database: invalid path %q", path)
+	}
+
+	// Use the pure-Go driver: it ships with FTS5 and does not need cgo,
+	// which release builds disable.
+	dialector := sqlite.Dialector{DriverName: "sqlite", DSN: path + "?_pragma=foreign_keys(1)&_time_format=sqlite"}
+	db, err := gorm.Open(dialector, &gorm.Config{
+		CreateBatchSize: 100,
+		Logger:          logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	return db, nil
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// migrate creates the normalized schema, the chunk_records view and the
+// full-text search index.
+func migrate(db *gorm.DB) error {
+	// Databases written by earlier versions have a chunk_records table
+	// where the view belongs; keep it under a different name and copy its
+	// rows into messages.
+	var legacy int64
+	if err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'chunk_records'").Scan(&legacy).Error; err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+	if legacy > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("ALTER TABLE chunk_records RENAME TO chunk_records_legacy").Error; err != nil {
+				return fmt.Errorf("error migrating database: %w", err)
+			}
+			return backfillLegacyChunks(tx)
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	// Only insert if there are records
-	if len(records) > 0 {
-		if err := db.Create(&records).Error; err != nil {
-			return fmt.Errorf("error inserting chunks: %w", err)
+	if err := db.AutoMigrate(&ConversationRecord{}, &MessageRecord{}, &PartRecord{}, &ThoughtRecord{}, &AttachmentRecord{}); err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+	if err := db.Exec(chunkRecordsView).Error; err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+
+	if !db.Migrator().HasTable("messages_fts") {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, statement := range messagesFTS {
+				if err := tx.Exec(statement).Error; err != nil {
+					return fmt.Errorf("error creating search index: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 
+	return db.Transaction(backfillHashes)
+}
+
+// legacySource is the source_path of the conversation that holds the rows
+// of a chunk_records table written by earlier versions, which did not record
+// the conversation, role or order of their chunks.
+const legacySource = "chunk_records_legacy"
+
+// backfillLegacyChunks copies the rows of chunk_records_legacy into the
+// messages of a conversation of their own, in the order of their IDs, so
+// that they appear in the chunk_records view and the search index.
+func backfillLegacyChunks(tx *gorm.DB) error {
+	var chunks []struct {
+		ID   uint
+		Text string
+	}
+	if err := tx.Raw("SELECT id, text FROM chunk_records_legacy ORDER BY id").Scan(&chunks).Error; err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	if err := tx.AutoMigrate(&ConversationRecord{}, &MessageRecord{}); err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+	conversation, err := newConversationRecord(Root{Source: legacySource})
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(&conversation).Error; err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+
+	messages := make([]MessageRecord, 0, len(chunks))
+	for i, chunk := range chunks {
+		message := newMessageRecord(i, Chunk{}, chunk.Text)
+		message.ConversationID = conversation.ID
+		messages = append(messages, message)
+	}
+	if err := tx.CreateInBatches(&messages, 500).Error; err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+	return nil
+}
+
+// backfillHashes computes the hashes of conversations stored before they
+// were recorded, so that re-importing them is recognized.
+func backfillHashes(tx *gorm.DB) error {
+	var conversations []ConversationRecord
+	if err := tx.Where("hash = '' OR hash IS NULL").Find(&conversations).Error; err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+
+	for _, conversation := range conversations {
+		var messages []MessageRecord
+		if err := tx.Where("conversation_id = ?", conversation.ID).Order("ordinal, id").Find(&messages).Error; err != nil {
+			return fmt.Errorf("error migrating database: %w", err)
+		}
+
+		last := ""
+		for _, message := range messages {
+			last = messageHash(last, message)
+			if err := tx.Model(&message).Update("hash", last).Error; err != nil {
+				return fmt.Errorf("error migrating database: %w", err)
+			}
+		}
+		if err := tx.Model(&conversation).Update("hash", conversationHash(conversation, last)).Error; err != nil {
+			return fmt.Errorf("error migrating database: %w", err)
+		}
+	}
 	return nil
 }
+
+// messageHash returns the hash of message chained to the hash of the
+// messages before it, so that equal hashes mean equal conversations up to
+// and including the message.
+func messageHash(previous string, message MessageRecord) string {
+	h := sha256.New()
+	writeHashFields(h, previous, message.Role, strconv.FormatBool(message.IsThought), message.Text)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// conversationHash returns the hash of a conversation's settings and system
+// instruction together with lastMessage, the hash of its last message.
+func conversationHash(conversation ConversationRecord, lastMessage string) string {
+	h := sha256.New()
+	writeHashFields(h, conversation.Settings, conversation.SystemInstruction, lastMessage)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashFields writes each field with its length, so that different
+// field splits never hash alike.
+func writeHashFields(w io.Writer, fields ...string) {
+	for _, field := range fields {
+		fmt.Fprintf(w, "%d:%s", len(field), field)
+	}
+}
+
+func newConversationRecord(root Root) (ConversationRecord, error) {
+	settings, err := json.Marshal(root.RunSettings)
+	if err != nil {
+		return ConversationRecord{}, fmt.Errorf("error encoding run settings: %w", err)
+	}
+
+	conversation := ConversationRecord{
+		SourcePath:        root.Source,
+		Model:             root.RunSettings.Model,
+		Settings:          string(settings),
+		SystemInstruction: root.SystemInstruction.Content(),
+		ImportedAt:        time.Now().UTC(),
+	}
+
+	return conversation, nil
+}
+
+func newMessageRecord(ordinal int, chunk Chunk, text string) MessageRecord {
+	message := MessageRecord{
+		Ordinal:      ordinal,
+		Role:         chunk.Role,
+		IsThought:    chunk.IsThought,
+		TokenCount:   chunk.TokenCount,
+		FinishReason: chunk.FinishReason,
+		Text:         text,
+	}
+	for i, part := range chunk.Parts {
+		message.Parts = append(message.Parts, PartRecord{
+			Ordinal:          i,
+			Text:             part.Text,
+			Thought:          part.Thought,
+			ThoughtSignature: part.ThoughtSignature,
+		})
+	}
+	return message
+}
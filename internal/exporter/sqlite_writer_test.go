@@ -0,0 +1,409 @@
+package exporter
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSQLiteWriter_NormalizedSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	roots := []Root{
+		{
+			Source:            "first.json",
+			RunSettings:       RunSettings{Model: "models/gemini-pro", TopK: 64},
+			SystemInstruction: SystemInstruction{Text: "Be brief."},
+			ChunkedPrompt: ChunkedPrompt{
+				Chunks: []Chunk{
+					{Text: "Question", Role: RoleUser, TokenCount: 2},
+					{Text: "Thought", Role: RoleModel, IsThought: true},
+					{
+						Text: "Answer", Role: RoleModel, TokenCount: 4, FinishReason: "STOP",
+						Parts: []Part{{Text: "Answer"}, {ThoughtSignature: "sig"}},
+					},
+				},
+			},
+		},
+		{
+			Source: "second.json",
+			ChunkedPrompt: ChunkedPrompt{
+				Chunks: []Chunk{{Text: "Other", Role: RoleUser}},
+			},
+		},
+	}
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	for _, root := range roots {
+		if err := writer.Write(root); err != nil {
+			t.Fatalf("SQLiteWriter.Write failed: %v", err)
+		}
+	}
+
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	var conversations []ConversationRecord
+	if err := db.Preload("Messages.Parts").Order("id").Find(&conversations).Error; err != nil {
+		t.Fatalf("Failed to query conversations: %v", err)
+	}
+
+	if len(conversations) != 2 {
+		t.Fatalf("Expected 2 conversations, got %d", len(conversations))
+	}
+
+	first := conversations[0]
+	if first.SourcePath != "first.json" || first.Model != "models/gemini-pro" || first.SystemInstruction != "Be brief." {
+		t.Errorf("Unexpected conversation: %+v", first)
+	}
+	if first.ImportedAt.IsZero() {
+		t.Error("Expected imported_at to be set")
+	}
+	if first.Settings == "" || first.Settings[0] != '{' {
+		t.Errorf("Expected settings JSON, got %q", first.Settings)
+	}
+
+	if len(first.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(first.Messages))
+	}
+	answer := first.Messages[1]
+	if answer.Ordinal != 2 || answer.Role != RoleModel || answer.FinishReason != "STOP" || answer.TokenCount != 4 {
+		t.Errorf("Unexpected message: %+v", answer)
+	}
+	if len(answer.Parts) != 2 || answer.Parts[1].ThoughtSignature != "sig" || answer.Parts[1].Ordinal != 1 {
+		t.Errorf("Unexpected parts: %+v", answer.Parts)
+	}
+
+	if conversations[1].SourcePath != "second.json" || len(conversations[1].Messages) != 1 {
+		t.Errorf("Unexpected second conversation: %+v", conversations[1])
+	}
+
+	var records []ChunkRecord
+	if err := db.Order("id").Find(&records).Error; err != nil {
+		t.Fatalf("Failed to query chunk_records view: %v", err)
+	}
+	if len(records) != 3 || records[2].Text != "Other" {
+		t.Errorf("Unexpected chunk_records: %+v", records)
+	}
+}
+
+func TestSQLiteWriter_ForeignKeys(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := writer.Write(Root{}); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := MessageRecord{ConversationID: 42, Text: "Orphan"}
+	if err := db.Create(&orphan).Error; err == nil {
+		t.Error("Expected foreign key violation for orphan message, got nil")
+	}
+}
+
+func TestSQLiteWriter_OpensOnce(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := writer.Write(conversation("a.json", "Hi")); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+	db := writer.db
+	if err := writer.Write(conversation("b.json", "Bye")); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+	if db == nil || writer.db != db {
+		t.Error("Expected the database to stay open between exports")
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if writer.db != nil {
+		t.Error("Expected Close to close the database")
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+
+	// A closed writer opens the database again.
+	if err := writer.Write(conversation("c.json", "Ciao")); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+	defer writer.Close()
+	if got := storedConversations(t, dbPath); len(got) != 3 {
+		t.Errorf("Conversations = %q, want 3", got)
+	}
+}
+
+func TestSQLiteWriter_LegacyTable(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.Exec("CREATE TABLE chunk_records (id integer PRIMARY KEY, text text NOT NULL)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO chunk_records (text) VALUES ('old'), ('older')").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	root := Root{ChunkedPrompt: ChunkedPrompt{Chunks: []Chunk{{Text: "new"}}}}
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	var texts []string
+	if err := db.Raw("SELECT text FROM chunk_records").Scan(&texts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(texts, []string{"old", "older", "new"}) {
+		t.Errorf("Expected chunk_records view with legacy and new rows, got %v", texts)
+	}
+
+	if err := db.Raw("SELECT text FROM chunk_records_legacy").Scan(&texts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(texts, []string{"old", "older"}) {
+		t.Errorf("Expected legacy rows to be kept, got %v", texts)
+	}
+
+	if got := storedConversations(t, dbPath); !slices.Equal(got, []string{"chunk_records_legacy|old|older", "|new"}) {
+		t.Errorf("Unexpected conversations: %q", got)
+	}
+	results, err := Search(dbPath, "older", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].SourcePath != "chunk_records_legacy" {
+		t.Errorf("Expected the legacy row to be searchable, got %+v", results)
+	}
+
+	// Migrating again copies nothing.
+	if err := writer.Write(root); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+	if err := db.Raw("SELECT text FROM chunk_records").Scan(&texts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 3 {
+		t.Errorf("Expected legacy rows to be copied once, got %v", texts)
+	}
+}
+
+// conversation builds a prompt from alternating user and model messages.
+func conversation(source string, texts ...string) Root {
+	root := Root{Source: source}
+	for i, text := range texts {
+		role := RoleUser
+		if i%2 == 1 {
+			role = RoleModel
+		}
+		root.ChunkedPrompt.Chunks = append(root.ChunkedPrompt.Chunks, Chunk{Text: text, Role: role})
+	}
+	return root
+}
+
+// storedConversations returns the source and message texts of every stored
+// conversation.
+func storedConversations(t *testing.T, dbPath string) []string {
+	t.Helper()
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db)
+
+	var conversations []ConversationRecord
+	err = db.Preload("Messages", func(db *gorm.DB) *gorm.DB { return db.Order("ordinal") }).Order("id").Find(&conversations).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result []string
+	for _, conversation := range conversations {
+		texts := []string{conversation.SourcePath}
+		for _, message := range conversation.Messages {
+			texts = append(texts, message.Text)
+		}
+		result = append(result, strings.Join(texts, "|"))
+	}
+	return result
+}
+
+func TestSQLiteWriter_IncrementalImport(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     Root
+		second    Root
+		expected  []string
+		wantStats ImportStats
+	}{
+		{
+			name:      "Unchanged",
+			first:     conversation("a.json", "Hi", "Hello"),
+			second:    conversation("a.json", "Hi", "Hello"),
+			expected:  []string{"a.json|Hi|Hello"},
+			wantStats: ImportStats{Inserted: 1, Skipped: 1, Messages: 2},
+		},
+		{
+			name:      "Same content from another source",
+			first:     conversation("a.json", "Hi", "Hello"),
+			second:    conversation("copy.json", "Hi", "Hello"),
+			expected:  []string{"a.json|Hi|Hello"},
+			wantStats: ImportStats{Inserted: 1, Skipped: 1, Messages: 2},
+		},
+		{
+			name:      "Grown",
+			first:     conversation("a.json", "Hi", "Hello"),
+			second:    conversation("a.json", "Hi", "Hello", "Bye", "Goodbye"),
+			expected:  []string{"a.json|Hi|Hello|Bye|Goodbye"},
+			wantStats: ImportStats{Inserted: 1, Updated: 1, Messages: 4},
+		},
+		{
+			name:      "Earlier version",
+			first:     conversation("a.json", "Hi", "Hello", "Bye"),
+			second:    conversation("a.json", "Hi", "Hello"),
+			expected:  []string{"a.json|Hi|Hello|Bye"},
+			wantStats: ImportStats{Inserted: 1, Skipped: 1, Messages: 3},
+		},
+		{
+			name:      "Grown from another source",
+			first:     conversation("a.json", "Hi", "Hello"),
+			second:    conversation("b.json", "Hi", "Hello", "Bye"),
+			expected:  []string{"a.json|Hi|Hello", "b.json|Hi|Hello|Bye"},
+			wantStats: ImportStats{Inserted: 2, Messages: 5},
+		},
+		{
+			name:      "Edited",
+			first:     conversation("a.json", "Hi", "Hello", "Bye"),
+			second:    conversation("a.json", "Hi", "Hello", "Ciao"),
+			expected:  []string{"a.json|Hi|Hello|Bye", "a.json|Hi|Hello|Ciao"},
+			wantStats: ImportStats{Inserted: 2, Messages: 6},
+		},
+		{
+			name:  "Settings changed",
+			first: conversation("a.json", "Hi", "Hello"),
+			second: func() Root {
+				root := conversation("a.json", "Hi", "Hello")
+				root.RunSettings.Temperature = 0.5
+				return root
+			}(),
+			expected:  []string{"a.json|Hi|Hello"},
+			wantStats: ImportStats{Inserted: 1, Updated: 1, Messages: 2},
+		},
+		{
+			name:      "Empty",
+			first:     conversation("a.json"),
+			second:    conversation("a.json"),
+			expected:  []string{"a.json"},
+			wantStats: ImportStats{Inserted: 1, Skipped: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "test.db")
+
+			writer := &SQLiteWriter{DBPath: dbPath}
+			for _, root := range []Root{tt.first, tt.second} {
+				if err := writer.Write(root); err != nil {
+					t.Fatalf("SQLiteWriter.Write failed: %v", err)
+				}
+			}
+
+			if got := storedConversations(t, dbPath); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Conversations = %q, want %q", got, tt.expected)
+			}
+			if writer.Stats != tt.wantStats {
+				t.Errorf("Stats = %+v, want %+v", writer.Stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestSQLiteWriter_MetadataAfterChunks(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := ExportReader(strings.NewReader(lateMetadataInput), "late.json", writer); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+
+	// The same prompt with its metadata first is recognized as stored.
+	early := `{
+		"runSettings": {"model": "models/x"},
+		"systemInstruction": {"text": "SYS"},
+		"chunkedPrompt": {"chunks": [{"text": "Hi", "role": "user"}, {"text": "Hello!", "role": "model"}]}
+	}`
+	if err := ExportReader(strings.NewReader(early), "late.json", writer); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+	if expected := (ImportStats{Inserted: 1, Skipped: 1, Messages: 2}); writer.Stats != expected {
+		t.Errorf("Stats = %+v, want %+v", writer.Stats, expected)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(db)
+	var conversations []ConversationRecord
+	if err := db.Find(&conversations).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(conversations) != 1 {
+		t.Fatalf("Expected 1 conversation, got %d", len(conversations))
+	}
+	c := conversations[0]
+	if c.Model != "models/x" || c.SystemInstruction != "SYS" || !strings.Contains(c.Settings, `"model":"models/x"`) {
+		t.Errorf("Unexpected conversation: %+v", c)
+	}
+}
+
+func TestSQLiteWriter_BackfillHashes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := writer.Write(conversation("a.json", "Hi", "Hello")); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	// Simulate a database written before hashes were stored.
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, statement := range []string{"UPDATE conversations SET hash = ''", "UPDATE messages SET hash = NULL"} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writer = &SQLiteWriter{DBPath: dbPath}
+	if err := writer.Write(conversation("a.json", "Hi", "Hello", "Bye")); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+
+	expected := []string{"a.json|Hi|Hello|Bye"}
+	if got := storedConversations(t, dbPath); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Conversations = %q, want %q", got, expected)
+	}
+	if writer.Stats != (ImportStats{Updated: 1, Messages: 1}) {
+		t.Errorf("Unexpected stats: %+v", writer.Stats)
+	}
+}
@@ -0,0 +1,193 @@
+package exporter
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stats describes the size and shape of a conversation.
+type Stats struct {
+	Source string `json:"source,omitempty"`
+	Model  string `json:"model,omitempty"`
+	// Turns counts the turns of each role. A turn is a run of consecutive
+	// chunks with the same role, so a model's thoughts and its answer form
+	// one turn.
+	Turns         map[string]int `json:"turns"`
+	AnswerChunks  int            `json:"answerChunks"`
+	ThoughtChunks int            `json:"thoughtChunks"`
+	// Tokens sums the tokenCount of all chunks, of which ThoughtTokens is
+	// the share of thoughts.
+	Tokens        int `json:"tokens"`
+	ThoughtTokens int `json:"thoughtTokens"`
+	Words         int `json:"words"`
+	Characters    int `json:"characters"`
+	// LongestTurn is the turn with the most words, if any.
+	LongestTurn *TurnStats `json:"longestTurn,omitempty"`
+	// FinishReasons counts the chunks with each finish reason.
+	FinishReasons map[string]int `json:"finishReasons"`
+	// Cost is the estimated cost of the conversation, if prices are given.
+	Cost *Cost `json:"cost,omitempty"`
+}
+
+// TurnStats describes a single turn of a conversation.
+type TurnStats struct {
+	// Source is the conversation of the turn, set in summaries.
+	Source string `json:"source,omitempty"`
+	Turn   int    `json:"turn"`
+	Role   string `json:"role"`
+	Words  int    `json:"words"`
+	Tokens int    `json:"tokens"`
+}
+
+func newStats(source, model string) Stats {
+	return Stats{
+		Source:        source,
+		Model:         model,
+		Turns:         make(map[string]int),
+		FinishReasons: make(map[string]int),
+	}
+}
+
+// StatsSummary aggregates the statistics of several conversations.
+type StatsSummary struct {
+	Conversations int `json:"conversations"`
+	// Models counts the conversations of each model.
+	Models map[string]int `json:"models"`
+	Stats
+}
+
+// Summarize adds up the statistics of conversations.
+func Summarize(conversations []Stats) StatsSummary {
+	summary := StatsSummary{Models: make(map[string]int), Stats: newStats("", "")}
+	for _, stats := range conversations {
+		summary.Conversations++
+		if stats.Model != "" {
+			summary.Models[stats.Model]++
+		}
+		for role, n := range stats.Turns {
+			summary.Turns[role] += n
+		}
+		summary.AnswerChunks += stats.AnswerChunks
+		summary.ThoughtChunks += stats.ThoughtChunks
+		summary.Tokens += stats.Tokens
+		summary.ThoughtTokens += stats.ThoughtTokens
+		summary.Words += stats.Words
+		summary.Characters += stats.Characters
+		if turn := stats.LongestTurn; turn != nil && (summary.LongestTurn == nil || turn.Words > summary.LongestTurn.Words) {
+			longest := *turn
+			longest.Source = stats.Source
+			summary.LongestTurn = &longest
+		}
+		for reason, n := range stats.FinishReasons {
+			summary.FinishReasons[reason] += n
+		}
+		if stats.Cost != nil {
+			if summary.Cost == nil {
+				summary.Cost = &Cost{}
+			}
+			summary.Cost.Add(*stats.Cost)
+		}
+	}
+	return summary
+}
+
+// StatsWriter computes the statistics of every conversation exported to it,
+// thoughts included.
+type StatsWriter struct {
+	// Prices, if set, is used to estimate the Cost of each conversation.
+	Prices *PriceTable
+	// Stats receives the statistics of each conversation once it ends.
+	Stats []Stats
+
+	current Stats
+	turn    TurnStats
+	cost    costCounter
+}
+
+// Write computes the statistics of root.
+func (w *StatsWriter) Write(root Root) error {
+	return w.WriteContext(context.Background(), root)
+}
+
+// WriteContext is like Write but stops when ctx is cancelled.
+func (w *StatsWriter) WriteContext(ctx context.Context, root Root) error {
+	return writeDocument(ctx, w, root)
+}
+
+// Begin starts the statistics of a conversation.
+func (w *StatsWriter) Begin(meta Root) error {
+	w.current = newStats(meta.Source, meta.RunSettings.Model)
+	w.turn = TurnStats{}
+	w.cost = newCostCounter(meta)
+	return nil
+}
+
+// WriteChunk counts chunk.
+func (w *StatsWriter) WriteChunk(chunk Chunk) error {
+	role := chunk.Role
+	if role == "" {
+		role = "unknown"
+	}
+	if w.turn.Turn == 0 || role != w.turn.Role {
+		w.endTurn()
+		w.turn = TurnStats{Turn: w.turn.Turn + 1, Role: role}
+		w.current.Turns[role]++
+	}
+
+	w.cost.add(chunk)
+	for _, piece := range splitThoughts(chunk) {
+		text := piece.Content()
+		words := len(strings.Fields(text))
+		w.current.Words += words
+		w.current.Characters += utf8.RuneCountInString(text)
+		w.current.Tokens += piece.TokenCount
+		w.turn.Words += words
+		w.turn.Tokens += piece.TokenCount
+		if piece.IsThought {
+			w.current.ThoughtChunks++
+			w.current.ThoughtTokens += piece.TokenCount
+		} else {
+			w.current.AnswerChunks++
+		}
+		if piece.FinishReason != "" {
+			w.current.FinishReasons[piece.FinishReason]++
+		}
+	}
+	return nil
+}
+
+// updateMeta takes the model and system instruction that follow the chunks.
+func (w *StatsWriter) updateMeta(meta Root) error {
+	w.current.Source, w.current.Model = meta.Source, meta.RunSettings.Model
+	w.cost.updateMeta(meta)
+	return nil
+}
+
+// endTurn records the turn in progress if it is the longest so far.
+func (w *StatsWriter) endTurn() {
+	if w.turn.Turn == 0 {
+		return
+	}
+	if longest := w.current.LongestTurn; longest == nil || w.turn.Words > longest.Words {
+		turn := w.turn
+		w.current.LongestTurn = &turn
+	}
+}
+
+// End records the statistics of the conversation.
+func (w *StatsWriter) End() error {
+	w.endTurn()
+	if w.Prices != nil {
+		cost := w.cost.result(*w.Prices)
+		cost.Source, cost.Model = "", ""
+		w.current.Cost = &cost
+	}
+	w.Stats = append(w.Stats, w.current)
+	return nil
+}
+
+// Abort drops the statistics of the conversation.
+func (w *StatsWriter) Abort() {
+	w.current = Stats{}
+}
@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func statsRoot() Root {
+	return Root{
+		Source:      "prompt.json",
+		RunSettings: RunSettings{Model: "models/gemini-2.5-pro"},
+		ChunkedPrompt: ChunkedPrompt{Chunks: []Chunk{
+			{Text: "Explain goroutines", Role: RoleUser, TokenCount: 3},
+			{Text: "Think first", Role: RoleModel, IsThought: true, TokenCount: 2},
+			{Role: RoleModel, TokenCount: 8, FinishReason: "STOP", Parts: []Part{
+				{Text: "Hmm ", Thought: true},
+				{Text: "Goroutines are lightweight threads"},
+			}},
+			{Text: "And channels?", Role: RoleUser, TokenCount: 3},
+			{Text: "They connect goroutines", Role: RoleModel, TokenCount: 4, FinishReason: "MAX_TOKENS"},
+		}},
+	}
+}
+
+func TestStatsWriter(t *testing.T) {
+	writer := &StatsWriter{}
+	if err := writer.Write(statsRoot()); err != nil {
+		t.Fatalf("StatsWriter.Write failed: %v", err)
+	}
+
+	expected := []Stats{{
+		Source:        "prompt.json",
+		Model:         "models/gemini-2.5-pro",
+		Turns:         map[string]int{RoleUser: 2, RoleModel: 2},
+		AnswerChunks:  4,
+		ThoughtChunks: 2,
+		Tokens:        20,
+		ThoughtTokens: 2,
+		Words:         14,
+		Characters:    103,
+		LongestTurn:   &TurnStats{Turn: 2, Role: RoleModel, Words: 7, Tokens: 10},
+		FinishReasons: map[string]int{"STOP": 1, "MAX_TOKENS": 1},
+	}}
+	if !reflect.DeepEqual(writer.Stats, expected) {
+		t.Errorf("Stats = %+v, want %+v", writer.Stats, expected)
+	}
+}
+
+func TestStatsWriter_MetadataAfterChunks(t *testing.T) {
+	writer := &StatsWriter{}
+	if err := ExportReader(strings.NewReader(lateMetadataInput), "late.json", writer); err != nil {
+		t.Fatalf("ExportReader failed: %v", err)
+	}
+	if len(writer.Stats) != 1 || writer.Stats[0].Model != "models/x" || writer.Stats[0].Source != "late.json" {
+		t.Errorf("Unexpected stats: %+v", writer.Stats)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	writer := &StatsWriter{}
+	root := statsRoot()
+	if err := writer.Write(root); err != nil {
+		t.Fatal(err)
+	}
+	root.Source = "other.json"
+	root.RunSettings.Model = "models/gemini-2.5-flash"
+	root.ChunkedPrompt.Chunks = []Chunk{{Text: "One two three four five six seven eight", Role: RoleUser, TokenCount: 9}}
+	if err := writer.Write(root); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := Summarize(writer.Stats)
+	if summary.Conversations != 2 || !reflect.DeepEqual(summary.Models, map[string]int{"models/gemini-2.5-pro": 1, "models/gemini-2.5-flash": 1}) {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if summary.Tokens != 29 || summary.Words != 22 || summary.Turns[RoleUser] != 3 || summary.FinishReasons["STOP"] != 1 {
+		t.Errorf("Unexpected totals: %+v", summary.Stats)
+	}
+	if expected := (&TurnStats{Source: "other.json", Turn: 1, Role: RoleUser, Words: 8, Tokens: 9}); !reflect.DeepEqual(summary.LongestTurn, expected) {
+		t.Errorf("LongestTurn = %+v, want %+v", summary.LongestTurn, expected)
+	}
+}
+
+func TestReadDatabase(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+
+	writer := &SQLiteWriter{DBPath: dbPath, Thoughts: SeparateThoughts}
+	if err := writer.Write(statsRoot()); err != nil {
+		t.Fatalf("SQLiteWriter.Write failed: %v", err)
+	}
+	if !IsDatabase(dbPath) {
+		t.Error("Expected the export to be recognised as a database")
+	}
+
+	var roots []Root
+	err := ReadDatabase(dbPath, func(root Root) error {
+		roots = append(roots, root)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadDatabase failed: %v", err)
+	}
+	if len(roots) != 1 || roots[0].Source != "prompt.json" || roots[0].RunSettings.Model != "models/gemini-2.5-pro" {
+		t.Fatalf("Unexpected conversations: %+v", roots)
+	}
+
+	// Statistics read back from the database match those of the prompt.
+	fromPrompt, fromDatabase := &StatsWriter{}, &StatsWriter{}
+	if err := fromPrompt.Write(statsRoot()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fromDatabase.Write(roots[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromDatabase.Stats, fromPrompt.Stats) {
+		t.Errorf("Stats = %+v, want %+v", fromDatabase.Stats, fromPrompt.Stats)
+	}
+
+	jsonPath := filepath.Join(dir, "prompt.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"chunkedPrompt": {"chunks": []}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if IsDatabase(jsonPath) {
+		t.Error("Expected a prompt not to be recognised as a database")
+	}
+	if err := ReadDatabase(jsonPath, func(Root) error { return nil }); err == nil {
+		t.Error("Expected error reading a prompt as a database")
+	}
+}
@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamChunks decodes a prompt from r and calls fn for each chunk as soon as
+// it has been decoded, so memory use is bounded by the largest chunk rather
+// than by the size of the document. The returned Root holds every other
+// field of the document; its chunk list is left empty.
+func StreamChunks(r io.Reader, fn func(Chunk) error) (Root, error) {
+	return streamChunks(r, nil, fn)
+}
+
+// streamChunks works like StreamChunks and additionally calls begin, if not
+// nil, with the fields decoded so far right before the chunk list is read.
+func streamChunks(r io.Reader, begin func(Root) error, fn func(Chunk) error) (Root, error) {
+	var root Root
+	decoder := json.NewDecoder(r)
+
+	err := decodeObject(decoder, func(key string) error {
+		if key == "chunkedPrompt" {
+			return decodeChunkedPrompt(decoder, &root, begin, fn)
+		}
+		return decodeField(decoder, key, &root)
+	})
+	if err != nil {
+		return Root{}, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return Root{}, errors.New("error parsing JSON: unexpected data after top-level value")
+	}
+
+	return root, nil
+}
+
+func decodeChunkedPrompt(decoder *json.Decoder, root *Root, begin func(Root) error, fn func(Chunk) error) error {
+	return decodeObject(decoder, func(key string) error {
+		if key != "chunks" {
+			return decodeField(decoder, key, &root.ChunkedPrompt)
+		}
+
+		if begin != nil {
+			if err := begin(*root); err != nil {
+				return err
+			}
+			begin = nil
+		}
+
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("error parsing JSON: %w", err)
+		}
+		if token == nil {
+			return nil
+		}
+		if token != json.Delim('[') {
+			return fmt.Errorf("error parsing JSON: chunks must be an array, got %v", token)
+		}
+
+		for decoder.More() {
+			var chunk Chunk
+			if err := decoder.Decode(&chunk); err != nil {
+				return fmt.Errorf("error parsing JSON: %w", err)
+			}
+			if err := fn(chunk); err != nil {
+				return err
+			}
+		}
+
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("error parsing JSON: %w", err)
+		}
+		return nil
+	})
+}
+
+// decodeObject reads a JSON object from decoder, calling field for each key
+// with the decoder positioned at its value. A null value is accepted as an
+// empty object.
+func decodeObject(decoder *json.Decoder, field func(key string) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("error parsing JSON: %w", err)
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("error parsing JSON: expected object, got %v", token)
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("error parsing JSON: %w", err)
+		}
+		if err := field(token.(string)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("error parsing JSON: %w", err)
+	}
+	return nil
+}
+
+// decodeField decodes the value of key into the matching field of v, using
+// the field's JSON tags. Keys without a matching field are skipped.
+func decodeField(decoder *json.Decoder, key string, v any) error {
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	object, err := json.Marshal(map[string]json.RawMessage{key: value})
+	if err != nil {
+		return fmt.Errorf("error parsing JSON: %w", err)
+	}
+	if err := json.Unmarshal(object, v); err != nil {
+		return fmt.Errorf("error parsing JSON: %w", err)
+	}
+	return nil
+}
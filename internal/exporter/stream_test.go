@@ -0,0 +1,312 @@
+package exporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStreamChunks(t *testing.T) {
+	input := `{
+		"chunkedPrompt": {
+			"chunks": [
+				{"text": "One", "role": "user"},
+				{"text": "Two", "role": "model", "inlineImage": {"data": "AAAA"}}
+			],
+			"pendingInputs": [{"text": "", "role": "user"}]
+		},
+		"runSettings": {"model": "models/gemini-pro", "topK": 64},
+		"systemInstruction": {"text": "Be brief."},
+		"unknown": [1, 2, {"nested": true}]
+	}`
+
+	var texts []string
+	root, err := StreamChunks(strings.NewReader(input), func(chunk Chunk) error {
+		texts = append(texts, chunk.Role+":"+chunk.Text)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChunks failed: %v", err)
+	}
+
+	if strings.Join(texts, ",") != "user:One,model:Two" {
+		t.Errorf("Unexpected chunks: %v", texts)
+	}
+	if root.RunSettings.Model != "models/gemini-pro" || root.RunSettings.TopK != 64 {
+		t.Errorf("Unexpected run settings: %+v", root.RunSettings)
+	}
+	if root.SystemInstruction.Text != "Be brief." {
+		t.Errorf("Unexpected system instruction: %+v", root.SystemInstruction)
+	}
+	if len(root.ChunkedPrompt.Chunks) != 0 {
+		t.Errorf("Expected no materialized chunks, got %d", len(root.ChunkedPrompt.Chunks))
+	}
+}
+
+func TestStreamChunks_Nulls(t *testing.T) {
+	for _, input := range []string{
+		`{}`,
+		`{"chunkedPrompt": null}`,
+		`{"chunkedPrompt": {"chunks": null}}`,
+	} {
+		calls := 0
+		if _, err := StreamChunks(strings.NewReader(input), func(Chunk) error {
+			calls++
+			return nil
+		}); err != nil {
+			t.Errorf("StreamChunks(%s) failed: %v", input, err)
+		}
+		if calls != 0 {
+			t.Errorf("StreamChunks(%s) produced %d chunks", input, calls)
+		}
+	}
+}
+
+func TestStreamChunks_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Invalid JSON", "invalid json"},
+		{"Not an object", `[{"chunkedPrompt": {}}]`},
+		{"Chunks not an array", `{"chunkedPrompt": {"chunks": {}}}`},
+		{"Truncated", `{"chunkedPrompt": {"chunks": [{"text": "One"}`},
+		{"Invalid chunk", `{"chunkedPrompt": {"chunks": [{"text": 1}]}}`},
+		{"Trailing data", `{} {}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StreamChunks(strings.NewReader(tt.input), func(Chunk) error { return nil }); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestStreamChunks_CallbackError(t *testing.T) {
+	input := `{"chunkedPrompt": {"chunks": [{"text": "One"}, {"text": "Two"}]}}`
+	stop := errors.New("stop")
+
+	calls := 0
+	_, err := StreamChunks(strings.NewReader(input), func(Chunk) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected decoding to stop after the first chunk, got %d calls", calls)
+	}
+}
+
+// syntheticPrompt returns a reader producing a prompt with the given number of
+// chunks, each carrying an inline image of payloadSize bytes, without holding
+// the document in memory.
+func syntheticPrompt(chunks, payloadSize int) (io.Reader, int64) {
+	payload := bytes.Repeat([]byte("A"), payloadSize)
+	chunk := []byte(fmt.Sprintf(`{"text": "Look at this", "role": "user", "inlineImage": {"mimeType": "image/png", "data": "%s"}}`, payload))
+
+	readers := []io.Reader{strings.NewReader(`{"runSettings": {"model": "models/gemini-pro"}, "chunkedPrompt": {"chunks": [`)}
+	size := int64(0)
+	for i := 0; i < chunks; i++ {
+		if i > 0 {
+			readers = append(readers, strings.NewReader(","))
+			size++
+		}
+		readers = append(readers, bytes.NewReader(chunk))
+		size += int64(len(chunk))
+	}
+	readers = append(readers, strings.NewReader(`]}}`))
+
+	return io.MultiReader(readers...), size
+}
+
+// peakHeapDuringStream streams r and returns the largest heap size observed
+// while chunks were being handed over.
+func peakHeapDuringStream(t testing.TB, r io.Reader) uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	var peak uint64
+	count := 0
+	_, err := StreamChunks(r, func(chunk Chunk) error {
+		count++
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > peak {
+			peak = stats.HeapAlloc
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChunks failed: %v", err)
+	}
+	if count == 0 {
+		t.Fatal("Expected chunks to be streamed")
+	}
+	return peak
+}
+
+func TestStreamChunks_BoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large input in short mode")
+	}
+
+	// 64 chunks of 1 MiB each: the heap must stay far below the input size.
+	r, size := syntheticPrompt(64, 1<<20)
+	peak := peakHeapDuringStream(t, r)
+
+	if peak > uint64(size)/4 {
+		t.Errorf("Peak heap %d MiB for %d MiB input; expected memory to be bounded by chunk size", peak>>20, size>>20)
+	}
+}
+
+// BenchmarkStreamChunks streams a ~300 MiB synthetic prompt and reports the
+// peak heap, which stays close to the size of a single chunk.
+func BenchmarkStreamChunks(b *testing.B) {
+	const chunks, payloadSize = 300, 1 << 20
+
+	for i := 0; i < b.N; i++ {
+		r, size := syntheticPrompt(chunks, payloadSize)
+		b.SetBytes(size)
+		peak := peakHeapDuringStream(b, r)
+		b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MiB")
+		b.ReportMetric(float64(size)/(1<<20), "input-MiB")
+	}
+}
+
+// recordingWriter records the calls made to a whole-document writer.
+type recordingWriter struct {
+	roots []Root
+}
+
+func (w *recordingWriter) Write(root Root) error {
+	w.roots = append(w.roots, root)
+	return nil
+}
+
+func TestExportStream_AdaptWriter(t *testing.T) {
+	input := `{"runSettings": {"model": "m"}, "chunkedPrompt": {"chunks": [{"text": "One"}, {"text": "Two", "isThought": true}]}}`
+
+	recorder := &recordingWriter{}
+	if err := ExportStream(strings.NewReader(input), "input.json", AdaptWriter(recorder)); err != nil {
+		t.Fatalf("ExportStream failed: %v", err)
+	}
+
+	if len(recorder.roots) != 1 {
+		t.Fatalf("Expected one Write call, got %d", len(recorder.roots))
+	}
+	root := recorder.roots[0]
+	if root.Source != "input.json" || root.RunSettings.Model != "m" || len(root.ChunkedPrompt.Chunks) != 2 {
+		t.Errorf("Unexpected document: %+v", root)
+	}
+}
+
+// streamRecorder records the calls made to a stream writer.
+type streamRecorder struct {
+	calls []string
+}
+
+func (w *streamRecorder) Begin(meta Root) error {
+	w.calls = append(w.calls, "begin:"+meta.Source+":"+meta.RunSettings.Model)
+	return nil
+}
+
+func (w *streamRecorder) WriteChunk(chunk Chunk) error {
+	w.calls = append(w.calls, "chunk:"+chunk.Text)
+	return nil
+}
+
+func (w *streamRecorder) End() error {
+	w.calls = append(w.calls, "end")
+	return nil
+}
+
+func (w *streamRecorder) Abort() {
+	w.calls = append(w.calls, "abort")
+}
+
+func TestExportStream_Calls(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Metadata first",
+			input:    `{"runSettings": {"model": "m"}, "chunkedPrompt": {"chunks": [{"text": "One"}, {"text": "Two"}]}}`,
+			expected: "begin:in:m,chunk:One,chunk:Two,end",
+		},
+		{
+			name:     "Metadata after chunks",
+			input:    `{"chunkedPrompt": {"chunks": [{"text": "One"}]}, "runSettings": {"model": "m"}}`,
+			expected: "begin:in:,chunk:One,end",
+		},
+		{
+			name:     "No chunks",
+			input:    `{"runSettings": {"model": "m"}}`,
+			expected: "begin:in:m,end",
+		},
+		{
+			name:     "Truncated",
+			input:    `{"chunkedPrompt": {"chunks": [{"text": "One"}, {"te`,
+			expected: "begin:in:,chunk:One,abort",
+		},
+		{
+			name:     "Invalid before chunks",
+			input:    `{"runSettings": nope`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &streamRecorder{}
+			err := ExportStream(strings.NewReader(tt.input), "in", recorder)
+			if got := strings.Join(recorder.calls, ","); got != tt.expected {
+				t.Errorf("Calls = %q, want %q (err: %v)", got, tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestTextWriter_AbortRemovesPartialOutput(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output.txt")
+	input := `{"chunkedPrompt": {"chunks": [{"text": "One"}, {"te`
+
+	writer := &TextWriter{OutputPath: outputPath}
+	if err := ExportStream(strings.NewReader(input), "in", writer); err == nil {
+		t.Fatal("Expected error for truncated input, got nil")
+	}
+
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("Expected partial output to be removed, got %v", err)
+	}
+}
+
+func TestSQLiteWriter_AbortRollsBack(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	input := `{"chunkedPrompt": {"chunks": [{"text": "One"}, {"te`
+
+	writer := &SQLiteWriter{DBPath: dbPath}
+	if err := ExportStream(strings.NewReader(input), "in", writer); err == nil {
+		t.Fatal("Expected error for truncated input, got nil")
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conversations, messages int64
+	db.Model(&ConversationRecord{}).Count(&conversations)
+	db.Model(&MessageRecord{}).Count(&messages)
+	if conversations != 0 || messages != 0 {
+		t.Errorf("Expected rollback, got %d conversations and %d messages", conversations, messages)
+	}
+}
@@ -1,22 +1,347 @@
 package exporter
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
-	"os"
+	"io"
+	"strings"
 )
 
+// metadataSeparator ends the metadata header of a text export.
+const metadataSeparator = "===\n"
+
+// Default speaker labels used by TextWriter when Roles is set.
+const (
+	DefaultUserLabel  = "User:"
+	DefaultModelLabel = "Model:"
+)
+
+func init() {
+	Register("txt", []string{"text"}, Factory{Extension: ".txt", Assets: true, New: func(opts WriterOptions) (Writer, error) {
+		return &TextWriter{
+			OutputPath: opts.OutputPath,
+			Output:     opts.Output,
+			Clobber:    opts.Clobber,
+			Metadata:   opts.Metadata,
+			Roles:      opts.Roles,
+			UserLabel:  opts.UserLabel,
+			ModelLabel: opts.ModelLabel,
+			Thoughts:   opts.Thoughts,
+			Assets:     opts.Assets,
+		}, nil
+	}})
+}
+
 // TextWriter writes chunks to a text file.
 type TextWriter struct {
 	OutputPath string
+	// Output, if set, receives the output instead of the file at OutputPath.
+	Output io.Writer
+	// Clobber selects what happens when the file at OutputPath already
+	// exists.
+	Clobber ClobberMode
+	// Metadata prepends a header with the run settings and system instruction.
+	// If these are not known when the export begins, as when they follow the
+	// chunks in the input, the text is held in memory until the export ends.
+	Metadata bool
+	// Roles prefixes each chunk with a speaker label derived from its role.
+	Roles bool
+	// UserLabel and ModelLabel override the default speaker labels.
+	UserLabel  string
+	ModelLabel string
+	// Thoughts selects the thoughts to export.
+	Thoughts ThoughtMode
+	// Assets, if set, receives the attachments of the chunks, which are
+	// referenced from the text as e.g. "[Image: assets/....png]".
+	Assets *AssetStore
+
+	file    *outputFile
+	source  string
+	out     *bufio.Writer
+	written bool
+	// meta is the metadata of the conversation. header is the metadata
+	// header written at Begin, if any; otherwise body holds the text
+	// written so far when Metadata is set.
+	meta   Root
+	header string
+	body   *bytes.Buffer
+	// mode is the thought mode of this output; thoughts receives the
+	// thoughts in SeparateThoughts mode.
+	mode     ThoughtMode
+	thoughts *TextWriter
 }
 
 // Write writes the chunks to a text file.
 func (w *TextWriter) Write(root Root) error {
-	outputContent := ProcessChunks(root)
+	return w.WriteContext(context.Background(), root)
+}
+
+// WriteContext is like Write but stops when ctx is cancelled, removing the
+// partial output file.
+func (w *TextWriter) WriteContext(ctx context.Context, root Root) error {
+	return writeDocument(ctx, w, root)
+}
+
+// Begin creates the output file.
+func (w *TextWriter) Begin(meta Root) error {
+	w.mode, w.thoughts = w.Thoughts, nil
+	if w.Thoughts == SeparateThoughts {
+		path, err := thoughtsOutput(w.OutputPath, w.Output)
+		if err != nil {
+			return err
+		}
+		thoughts := &TextWriter{
+			OutputPath: path,
+			Clobber:    w.Clobber,
+			Metadata:   w.Metadata,
+			Roles:      w.Roles,
+			UserLabel:  w.UserLabel,
+			ModelLabel: w.ModelLabel,
+			Thoughts:   OnlyThoughts,
+			Assets:     w.Assets,
+		}
+		if err := thoughts.Begin(meta); err != nil {
+			return err
+		}
+		w.mode, w.thoughts = ExcludeThoughts, thoughts
+	}
+
+	if w.Output != nil {
+		w.out = bufio.NewWriter(w.Output)
+	} else {
+		file, err := createOutput(w.OutputPath, w.Clobber)
+		if err != nil {
+			w.abortThoughts()
+			return err
+		}
+		w.file = file
+		w.out = bufio.NewWriter(file)
+	}
+	w.written = false
+	w.source = meta.Source
+	w.meta, w.header, w.body = meta, "", nil
+
+	if w.Metadata {
+		// AI Studio writes the run settings before the chunks.
+		if header := FormatMetadata(meta); header != "" {
+			w.header = header
+			w.out.WriteString(header + metadataSeparator)
+		} else {
+			w.body = new(bytes.Buffer)
+		}
+	}
+	return nil
+}
+
+// WriteChunk appends the text of a chunk selected by Thoughts, separated
+// from the previous one by "\n---\n".
+func (w *TextWriter) WriteChunk(chunk Chunk) error {
+	if w.thoughts != nil {
+		if err := w.thoughts.WriteChunk(chunk); err != nil {
+			return err
+		}
+	}
 
-	if err := os.WriteFile(w.OutputPath, []byte(outputContent), 0644); err != nil {
+	var out io.StringWriter = w.out
+	if w.body != nil {
+		out = w.body
+	}
+
+	for _, piece := range contentPieces(chunk, w.Assets != nil) {
+		if !w.mode.keeps(piece.IsThought) {
+			continue
+		}
+		text, err := w.text(piece)
+		if err != nil {
+			return err
+		}
+
+		if w.written {
+			out.WriteString("\n---\n")
+		}
+		w.written = true
+
+		if w.Roles {
+			if prefix := w.label(piece.Role, piece.IsThought); prefix != "" {
+				out.WriteString(prefix + " ")
+			}
+		}
+		if _, err := out.WriteString(text); err != nil {
+			return fmt.Errorf("error writing to output file: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateMeta takes the metadata for the header, which may follow the chunks.
+func (w *TextWriter) updateMeta(meta Root) error {
+	w.meta = meta
+	if w.thoughts != nil {
+		if err := w.thoughts.updateMeta(meta); err != nil {
+			return err
+		}
+	}
+	if w.Metadata && w.body == nil && FormatMetadata(meta) != w.header {
+		return w.rewriteHeader()
+	}
+	return nil
+}
+
+// rewriteHeader replaces the metadata header written at Begin with one for
+// the complete metadata, copying the text written so far to a new output
+// file. Output that went to Output cannot be rewritten.
+func (w *TextWriter) rewriteHeader() error {
+	if w.file == nil {
+		return fmt.Errorf("error writing output: metadata after the chunks changes the header already written")
+	}
+	if err := w.out.Flush(); err != nil {
+		return fmt.Errorf("error writing to output file: %w", err)
+	}
+	if _, err := w.file.Seek(int64(len(w.header)+len(metadataSeparator)), io.SeekStart); err != nil {
 		return fmt.Errorf("error writing to output file: %w", err)
 	}
 
+	file, err := createOutput(w.OutputPath, w.Clobber)
+	if err != nil {
+		return err
+	}
+	w.header = FormatMetadata(w.meta)
+	out := bufio.NewWriter(file)
+	out.WriteString(w.header + metadataSeparator)
+	if _, err := io.Copy(out, w.file); err != nil {
+		file.Discard()
+		return fmt.Errorf("error writing to output file: %w", err)
+	}
+	w.file.Discard()
+	w.file, w.out = file, out
+	return nil
+}
+
+// End flushes the output and, in SeparateThoughts mode, the thoughts, and
+// only then moves the finished files into place, so that a failure leaves
+// neither of them behind.
+func (w *TextWriter) End() error {
+	if err := w.flush(); err != nil {
+		w.Abort()
+		return err
+	}
+	thoughts := w.thoughts
+	if thoughts != nil {
+		if err := thoughts.flush(); err != nil {
+			w.Abort()
+			return err
+		}
+		w.thoughts = nil
+		if err := thoughts.commit(); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	return w.commit()
+}
+
+// flush writes the metadata header and the text held back, if Metadata is
+// set, and flushes the output.
+func (w *TextWriter) flush() error {
+	if w.body != nil {
+		w.out.WriteString(FormatMetadata(w.meta))
+		w.out.WriteString(metadataSeparator)
+		w.out.Write(w.body.Bytes())
+		w.body = nil
+	}
+	if err := w.out.Flush(); err != nil {
+		return fmt.Errorf("error writing to output file: %w", err)
+	}
 	return nil
 }
+
+// commit moves the flushed output file into place.
+func (w *TextWriter) commit() error {
+	file := w.file
+	w.file, w.out = nil, nil
+	if file != nil {
+		return file.Commit()
+	}
+	return nil
+}
+
+// Abort discards a partially written output file, leaving any previous file
+// at OutputPath intact. Output written to Output cannot be taken back and is
+// left as is.
+func (w *TextWriter) Abort() {
+	w.abortThoughts()
+	if w.file != nil {
+		w.file.Discard()
+	}
+	w.file, w.out, w.body = nil, nil, nil
+}
+
+func (w *TextWriter) abortThoughts() {
+	if w.thoughts != nil {
+		w.thoughts.Abort()
+		w.thoughts = nil
+	}
+}
+
+// text returns the text of chunk followed by references to its attachments,
+// if Assets is set.
+func (w *TextWriter) text(chunk Chunk) (string, error) {
+	text := chunk.Content()
+	if w.Assets == nil {
+		return text, nil
+	}
+	assets, err := saveAttachments(w.Assets, w.source, chunk)
+	if err != nil {
+		return "", err
+	}
+
+	output := outputName(w.OutputPath, w.Output)
+	for _, asset := range assets {
+		name := "File"
+		if asset.IsImage() {
+			name = "Image"
+		}
+		if asset.Path == "" {
+			name = "Drive " + strings.ToLower(name)
+		}
+		if text != "" {
+			text += "\n"
+		}
+		text += "[" + name + ": " + w.Assets.Link(asset, output) + "]"
+	}
+	return text, nil
+}
+
+// label returns the speaker label for role, marked as such for thoughts.
+func (w *TextWriter) label(role string, thought bool) string {
+	label := w.roleLabel(role)
+	if thought && label != "" {
+		return strings.TrimSuffix(label, ":") + " (thought):"
+	}
+	return label
+}
+
+// roleLabel returns the speaker label for role. Roles other than user and
+// model are labelled with their capitalized name; chunks without a role get
+// none.
+func (w *TextWriter) roleLabel(role string) string {
+	switch role {
+	case "":
+		return ""
+	case RoleUser:
+		return valueOr(w.UserLabel, DefaultUserLabel)
+	case RoleModel:
+		return valueOr(w.ModelLabel, DefaultModelLabel)
+	default:
+		return roleName(role) + ":"
+	}
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,143 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ThoughtMode selects which of the model's thoughts are exported.
+type ThoughtMode int
+
+const (
+	// ExcludeThoughts drops thoughts.
+	ExcludeThoughts ThoughtMode = iota
+	// IncludeThoughts exports thoughts alongside the conversation.
+	IncludeThoughts
+	// OnlyThoughts exports thoughts and nothing else.
+	OnlyThoughts
+	// SeparateThoughts exports the conversation without thoughts and writes
+	// the thoughts to a sibling file (see ThoughtsPath) or, for SQLite, to
+	// the thoughts table.
+	SeparateThoughts
+)
+
+var thoughtModeNames = []string{"exclude", "include", "only", "separate"}
+
+// String returns the name of the mode as accepted by ParseThoughtMode.
+func (m ThoughtMode) String() string {
+	if m < 0 || int(m) >= len(thoughtModeNames) {
+		return fmt.Sprintf("ThoughtMode(%d)", int(m))
+	}
+	return thoughtModeNames[m]
+}
+
+// ParseThoughtMode returns the mode named s: exclude, include, only or
+// separate.
+func ParseThoughtMode(s string) (ThoughtMode, error) {
+	for i, name := range thoughtModeNames {
+		if strings.EqualFold(s, name) {
+			return ThoughtMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid thoughts mode: %s (supported: %s)", s, strings.Join(thoughtModeNames, ", "))
+}
+
+// keeps reports whether a chunk is part of the main output in mode m.
+func (m ThoughtMode) keeps(thought bool) bool {
+	switch m {
+	case IncludeThoughts:
+		return true
+	case OnlyThoughts:
+		return thought
+	default:
+		return !thought
+	}
+}
+
+// ThoughtsPath returns the file that receives the thoughts of the output at
+// path in SeparateThoughts mode, e.g. "chat.thoughts.md" for "chat.md".
+func ThoughtsPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".thoughts" + ext
+}
+
+// splitThoughts splits a chunk whose parts mix thoughts and answers into
+// consecutive chunks that are either all thought or not, so that the
+// per-part thought flag is honoured. The parts are authoritative for such
+// chunks; the token count and finish reason stay with the last piece.
+// Attachments of the chunk itself go with the last piece. Other chunks are
+// returned unchanged.
+func splitThoughts(chunk Chunk) []Chunk {
+	if chunk.IsThought || !hasThoughtParts(chunk.Parts) {
+		return []Chunk{chunk}
+	}
+
+	var pieces []Chunk
+	for _, part := range chunk.Parts {
+		if n := len(pieces); n > 0 && pieces[n-1].IsThought == part.Thought {
+			pieces[n-1].Parts = append(pieces[n-1].Parts, part)
+			continue
+		}
+		pieces = append(pieces, Chunk{Role: chunk.Role, IsThought: part.Thought, Parts: []Part{part}})
+	}
+
+	last := &pieces[len(pieces)-1]
+	last.TokenCount = chunk.TokenCount
+	last.FinishReason = chunk.FinishReason
+	last.InlineImage, last.InlineData = chunk.InlineImage, chunk.InlineData
+	last.DriveImage, last.DriveDocument = chunk.DriveImage, chunk.DriveDocument
+	return pieces
+}
+
+// contentPieces returns the pieces of chunk, split by splitThoughts, that
+// carry text or, if attachments is set, attachments. Every writer exports
+// chunks through it.
+func contentPieces(chunk Chunk, attachments bool) []Chunk {
+	var pieces []Chunk
+	for _, piece := range splitThoughts(chunk) {
+		if hasContent(piece, attachments) {
+			pieces = append(pieces, piece)
+		}
+	}
+	return pieces
+}
+
+// hasContent reports whether chunk carries text or, if attachments is set,
+// attachments.
+func hasContent(chunk Chunk, attachments bool) bool {
+	return chunk.Content() != "" || (attachments && len(chunk.Attachments()) > 0)
+}
+
+func hasThoughtParts(parts []Part) bool {
+	for _, part := range parts {
+		if part.Thought {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSeparately writes root without thoughts to output and its thoughts to
+// the ThoughtsPath of output, using the writers that variant returns for a
+// mode and an output path.
+func writeSeparately(root Root, output string, out io.Writer, variant func(mode ThoughtMode, path string) Writer) error {
+	path, err := thoughtsOutput(output, out)
+	if err != nil {
+		return err
+	}
+	if err := variant(ExcludeThoughts, output).Write(root); err != nil {
+		return err
+	}
+	return variant(OnlyThoughts, path).Write(root)
+}
+
+// thoughtsOutput checks that an output can be split for SeparateThoughts
+// and returns the path of the thoughts file.
+func thoughtsOutput(output string, out io.Writer) (string, error) {
+	if out != nil {
+		return "", fmt.Errorf("separate thoughts need an output file")
+	}
+	return ThoughtsPath(output), nil
+}
@@ -0,0 +1,282 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// thoughtRoot has a thought chunk, and an answer chunk whose parts start
+// with a thought that is only flagged on the part.
+var thoughtRoot = Root{
+	ChunkedPrompt: ChunkedPrompt{
+		Chunks: []Chunk{
+			{Text: "Question", Role: RoleUser},
+			{Text: "Pondering", Role: RoleModel, IsThought: true},
+			{
+				Role: RoleModel, TokenCount: 7, FinishReason: "STOP",
+				Parts: []Part{{Text: "Hidden", Thought: true}, {Text: "Answer"}},
+			},
+		},
+	},
+}
+
+func TestParseThoughtMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ThoughtMode
+		wantErr  bool
+	}{
+		{input: "exclude", expected: ExcludeThoughts},
+		{input: "include", expected: IncludeThoughts},
+		{input: "ONLY", expected: OnlyThoughts},
+		{input: "separate", expected: SeparateThoughts},
+		{input: "sometimes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := ParseThoughtMode(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %v", mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseThoughtMode failed: %v", err)
+			}
+			if mode != tt.expected || mode.String() != strings.ToLower(tt.input) {
+				t.Errorf("ParseThoughtMode(%q) = %v, want %v", tt.input, mode, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitThoughts(t *testing.T) {
+	pieces := splitThoughts(thoughtRoot.ChunkedPrompt.Chunks[2])
+
+	expected := []Chunk{
+		{Role: RoleModel, IsThought: true, Parts: []Part{{Text: "Hidden", Thought: true}}},
+		{Role: RoleModel, TokenCount: 7, FinishReason: "STOP", Parts: []Part{{Text: "Answer"}}},
+	}
+	if !reflect.DeepEqual(pieces, expected) {
+		t.Errorf("splitThoughts = %+v, want %+v", pieces, expected)
+	}
+
+	plain := Chunk{Text: "Plain", Parts: []Part{{Text: "Plain"}}}
+	if pieces := splitThoughts(plain); len(pieces) != 1 || !reflect.DeepEqual(pieces[0], plain) {
+		t.Errorf("Expected chunk without thought parts to be unchanged, got %+v", pieces)
+	}
+}
+
+func TestThoughtsPath(t *testing.T) {
+	tests := map[string]string{
+		"chat.md":        "chat.thoughts.md",
+		"out/chat.jsonl": "out/chat.thoughts.jsonl",
+		"chat":           "chat.thoughts",
+	}
+	for path, expected := range tests {
+		if got := ThoughtsPath(path); got != expected {
+			t.Errorf("ThoughtsPath(%q) = %q, want %q", path, got, expected)
+		}
+	}
+}
+
+func TestTextWriter_Thoughts(t *testing.T) {
+	tests := []struct {
+		mode     ThoughtMode
+		expected string
+	}{
+		{mode: ExcludeThoughts, expected: "User: Question\n---\nModel: Answer"},
+		{mode: IncludeThoughts, expected: "User: Question\n---\nModel (thought): Pondering\n---\nModel (thought): Hidden\n---\nModel: Answer"},
+		{mode: OnlyThoughts, expected: "Model (thought): Pondering\n---\nModel (thought): Hidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "output.txt")
+			writer := &TextWriter{OutputPath: outputPath, Roles: true, Thoughts: tt.mode}
+			if err := writer.Write(thoughtRoot); err != nil {
+				t.Fatalf("TextWriter.Write failed: %v", err)
+			}
+
+			result, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("Result = %q, want %q", string(result), tt.expected)
+			}
+		})
+	}
+}
+
+func TestSeparateThoughts(t *testing.T) {
+	tests := []struct {
+		name     string
+		writer   func(path string) Writer
+		output   []string
+		thoughts []string
+	}{
+		{
+			name:     "Text",
+			writer:   func(path string) Writer { return &TextWriter{OutputPath: path, Thoughts: SeparateThoughts} },
+			output:   []string{"Question", "Answer"},
+			thoughts: []string{"Pondering", "Hidden"},
+		},
+		{
+			name:     "Markdown",
+			writer:   func(path string) Writer { return &MarkdownWriter{OutputPath: path, Thoughts: SeparateThoughts} },
+			output:   []string{"Question", "Answer"},
+			thoughts: []string{"Pondering", "Hidden"},
+		},
+		{
+			name:     "HTML",
+			writer:   func(path string) Writer { return &HTMLWriter{OutputPath: path, Thoughts: SeparateThoughts} },
+			output:   []string{"Question", "Answer"},
+			thoughts: []string{"Pondering", "Hidden"},
+		},
+		{
+			name:     "Gemini JSONL",
+			writer:   func(path string) Writer { return &GeminiJSONLWriter{OutputPath: path, Thoughts: SeparateThoughts} },
+			output:   []string{"Question", "Answer"},
+			thoughts: []string{"Question", `{"text":"Pondering\n\nHidden","thought":true}`},
+		},
+	}
+
+	all := []string{"Question", "Pondering", "Hidden", "Answer"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "output.out")
+			if err := tt.writer(outputPath).Write(thoughtRoot); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			for path, expected := range map[string][]string{outputPath: tt.output, ThoughtsPath(outputPath): tt.thoughts} {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, text := range all {
+					want := false
+					for _, e := range expected {
+						if strings.Contains(e, text) {
+							want = true
+						}
+					}
+					if got := strings.Contains(string(content), text); got != want {
+						t.Errorf("%s: contains %q = %v, want %v", filepath.Base(path), text, got, want)
+					}
+				}
+				for _, e := range expected {
+					if !strings.Contains(string(content), e) {
+						t.Errorf("%s: expected %q in %q", filepath.Base(path), e, content)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTextWriter_SeparateThoughtsFailedFlush(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "chat.txt")
+	writeFiles(t, dir, map[string]string{"chat.thoughts.txt": "previous"})
+
+	writer := &TextWriter{OutputPath: output, Thoughts: SeparateThoughts}
+	if err := writer.Begin(Root{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteChunk(Chunk{Text: "Thinking", Role: RoleModel, IsThought: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteChunk(Chunk{Text: "Answer", Role: RoleModel}); err != nil {
+		t.Fatal(err)
+	}
+	// Make flushing the main output fail.
+	writer.file.Close()
+
+	if err := writer.End(); err == nil {
+		t.Fatal("Expected End to fail, got nil")
+	}
+	if content, err := os.ReadFile(ThoughtsPath(output)); err != nil || string(content) != "previous" {
+		t.Errorf("Thoughts file = %q (%v), want the previous one kept", content, err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected no main output, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected temporary files to be removed, got %d entries", len(entries))
+	}
+}
+
+func TestSeparateThoughts_Stdout(t *testing.T) {
+	var buf strings.Builder
+	writer := &MarkdownWriter{Output: &buf, Thoughts: SeparateThoughts}
+	if err := writer.Write(thoughtRoot); err == nil {
+		t.Error("Expected error for separate thoughts without an output file, got nil")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output, got %q", buf.String())
+	}
+}
+
+func TestSQLiteWriter_Thoughts(t *testing.T) {
+	tests := []struct {
+		mode     ThoughtMode
+		messages []string
+		thoughts []string
+	}{
+		{mode: ExcludeThoughts, messages: []string{"Question", "Answer"}},
+		{mode: IncludeThoughts, messages: []string{"Question", "thought:Pondering", "thought:Hidden", "Answer"}},
+		{mode: OnlyThoughts, messages: []string{"thought:Pondering", "thought:Hidden"}},
+		{mode: SeparateThoughts, messages: []string{"Question", "Answer"}, thoughts: []string{"Pondering", "Hidden"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "test.db")
+
+			// Importing twice must not duplicate anything.
+			writer := &SQLiteWriter{DBPath: dbPath, Thoughts: tt.mode}
+			for i := 0; i < 2; i++ {
+				if err := writer.Write(thoughtRoot); err != nil {
+					t.Fatalf("SQLiteWriter.Write failed: %v", err)
+				}
+			}
+
+			db, err := openDB(dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDB(db)
+
+			var messages []MessageRecord
+			if err := db.Order("ordinal, id").Find(&messages).Error; err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for _, message := range messages {
+				if message.IsThought {
+					texts = append(texts, "thought:"+message.Text)
+				} else {
+					texts = append(texts, message.Text)
+				}
+			}
+			if !reflect.DeepEqual(texts, tt.messages) {
+				t.Errorf("Messages = %q, want %q", texts, tt.messages)
+			}
+
+			var thoughts []string
+			if err := db.Model(&ThoughtRecord{}).Order("ordinal, id").Pluck("text", &thoughts).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(thoughts) != len(tt.thoughts) || (len(thoughts) > 0 && !reflect.DeepEqual(thoughts, tt.thoughts)) {
+				t.Errorf("Thoughts = %q, want %q", thoughts, tt.thoughts)
+			}
+		})
+	}
+}
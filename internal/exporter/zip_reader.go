@@ -0,0 +1,102 @@
+package exporter
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// zipInputs lists the entries of the zip archive at zipPath that contain an
+// AI Studio prompt or a ChatGPT or Claude export. Entries are sniffed for
+// their top-level keys, so Takeout and Drive exports without a .json
+// extension are found too. Each input is named after the archive followed by
+// the entry path.
+func zipInputs(zipPath string) ([]Input, error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening zip archive: %w", err)
+	}
+	defer archive.Close()
+
+	prefix := strings.TrimSuffix(filepath.Base(zipPath), filepath.Ext(zipPath))
+
+	var inputs []Input
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !isPromptEntry(file) {
+			continue
+		}
+		inputs = append(inputs, Input{
+			Path:  zipPath,
+			Entry: file.Name,
+			Rel:   filepath.Join(prefix, safeEntryPath(file.Name)),
+		})
+	}
+
+	return inputs, nil
+}
+
+// openZipEntry opens the named entry of the zip archive at zipPath. Closing
+// the returned reader also closes the archive.
+func openZipEntry(zipPath, name string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening zip archive: %w", err)
+	}
+
+	// Look the entry up by its raw name: archive.Open rejects names that
+	// are not valid fs paths, which Takeout archives may contain.
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+		entry, err := file.Open()
+		if err != nil {
+			archive.Close()
+			return nil, fmt.Errorf("error reading zip entry %s: %w", name, err)
+		}
+		return &zipEntryReader{ReadCloser: entry, archive: archive}, nil
+	}
+
+	archive.Close()
+	return nil, fmt.Errorf("error reading zip entry %s: not found", name)
+}
+
+type zipEntryReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (r *zipEntryReader) Close() error {
+	err := r.ReadCloser.Close()
+	if closeErr := r.archive.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// isPromptEntry reports whether the zip entry is an AI Studio prompt, a JSON
+// object with a top-level chunkedPrompt key, or a ChatGPT or Claude export.
+// Values of other keys are skipped without decoding the whole entry.
+func isPromptEntry(file *zip.File) bool {
+	rc, err := file.Open()
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+
+	_, ok := sniffFormat(rc)
+	return ok
+}
+
+// safeEntryPath converts a zip entry name to a relative file path that
+// cannot escape the directory it is joined to.
+func safeEntryPath(name string) string {
+	return filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+name), "/"))
+}
+
+func isZip(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeZip(t *testing.T, path string, entries []struct{ name, content string }) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandInputs_Zip(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "takeout.zip")
+	writeZip(t, zipPath, []struct{ name, content string }{
+		{"Takeout/Drive/", ""},
+		{"Takeout/Drive/My prompt", `{"runSettings": {"model": "m"}, "chunkedPrompt": {"chunks": [{"text": "Hello"}]}}`},
+		{"Takeout/Drive/other.json", `{"name": "not a prompt"}`},
+		{"Takeout/Drive/photo.jpg", "\xff\xd8\xff"},
+		{"../escape.json", `{"chunkedPrompt": {"chunks": [{"text": "Escaped"}]}}`},
+	})
+
+	inputs, err := ExpandInputs([]string{zipPath})
+	if err != nil {
+		t.Fatalf("ExpandInputs failed: %v", err)
+	}
+
+	expected := []Input{
+		{Path: zipPath, Entry: "Takeout/Drive/My prompt", Rel: filepath.Join("takeout", "Takeout", "Drive", "My prompt")},
+		{Path: zipPath, Entry: "../escape.json", Rel: filepath.Join("takeout", "escape.json")},
+	}
+	if !reflect.DeepEqual(inputs, expected) {
+		t.Fatalf("ExpandInputs() = %+v, want %+v", inputs, expected)
+	}
+
+	outDir := t.TempDir()
+	result := ExportBatch(inputs, func(input Input) (Writer, error) {
+		path := input.OutputPath(outDir, ".txt")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		return &TextWriter{OutputPath: path}, nil
+	})
+
+	if len(result.Failed) != 0 {
+		t.Fatalf("Unexpected failures: %+v", result.Failed)
+	}
+	if result.Succeeded[0] != zipPath+":Takeout/Drive/My prompt" {
+		t.Errorf("Unexpected source name %q", result.Succeeded[0])
+	}
+
+	content, err := os.ReadFile(filepath.Join(outDir, "takeout", "Takeout", "Drive", "My prompt.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "Hello" {
+		t.Errorf("Output = %q, want %q", content, "Hello")
+	}
+}
+
+func TestExpandInputs_ZipInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "drive"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeZip(t, filepath.Join(dir, "drive", "export.ZIP"), []struct{ name, content string }{
+		{"prompt", `{"chunkedPrompt": {"chunks": []}}`},
+	})
+
+	inputs, err := ExpandInputs([]string{dir})
+	if err != nil {
+		t.Fatalf("ExpandInputs failed: %v", err)
+	}
+
+	if len(inputs) != 1 || inputs[0].Entry != "prompt" || inputs[0].Rel != filepath.Join("drive", "export", "prompt") {
+		t.Errorf("Unexpected inputs: %+v", inputs)
+	}
+	if !IsBatch([]string{filepath.Join(dir, "drive", "export.ZIP")}) {
+		t.Error("Expected a zip archive to be exported as a batch")
+	}
+}
+
+func TestExpandInputs_InvalidZip(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"broken.zip": "not a zip"})
+
+	if _, err := ExpandInputs([]string{filepath.Join(dir, "broken.zip")}); err == nil {
+		t.Error("Expected error for invalid zip archive, got nil")
+	}
+}